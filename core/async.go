@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// Structs2PbAsync converts the structs received from beans until it is closed,
+// sending the messages as they are converted. The structs are converted
+// concurrently, so that a slow comment extraction does not hold up the others,
+// and the messages are sent in no particular order. The conversion stops at the
+// first error, which is sent on the error channel, as is the error of ctx if it
+// is done first. Both channels are closed when the conversion ends; see
+// CollectMessages.
+func Structs2PbAsync(ctx context.Context, beans <-chan interface{}, opts ...Option) (<-chan *Message, <-chan error) {
+	msgs := make(chan *Message)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(msgs)
+		convCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		// 只保留第一个错误
+		fail := func(err error) {
+			select {
+			case errs <- err:
+				cancel()
+			default:
+			}
+		}
+
+		var wg sync.WaitGroup
+		// 限制同时解析源码的数量
+		sem := make(chan struct{}, runtime.NumCPU())
+	loop:
+		for {
+			select {
+			case <-convCtx.Done():
+				break loop
+			case bean, ok := <-beans:
+				if !ok {
+					break loop
+				}
+				select {
+				case sem <- struct{}{}:
+				case <-convCtx.Done():
+					break loop
+				}
+				wg.Add(1)
+				go func(bean interface{}) {
+					defer wg.Done()
+					defer func() { <-sem }()
+					msg, err := Struct2PbWithContext(convCtx, bean, opts...)
+					if err != nil {
+						fail(err)
+						return
+					}
+					if msg == nil {
+						return
+					}
+					select {
+					case msgs <- msg:
+					case <-convCtx.Done():
+					}
+				}(bean)
+			}
+		}
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			fail(err)
+		}
+	}()
+	return msgs, errs
+}
+
+// CollectMessages receives the messages and the error of Structs2PbAsync until
+// the conversion ends or ctx is done, returning the messages received so far
+// with the error.
+func CollectMessages(ctx context.Context, msgs <-chan *Message, errs <-chan error) ([]*Message, error) {
+	var list []*Message
+	for {
+		select {
+		case <-ctx.Done():
+			return list, ctx.Err()
+		case msg, ok := <-msgs:
+			if !ok {
+				return list, <-errs
+			}
+			list = append(list, msg)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// validateTagKey is the struct tag key of go-playground/validator rules.
+	validateTagKey = "validate"
+
+	// bufValidateImport is the proto file declaring the protovalidate options.
+	bufValidateImport = "buf/validate/validate.proto"
+)
+
+// BufValidateOption adds a protovalidate rule, e.g. BufValidateOption("string.min_len = 1")
+// is rendered as `(buf.validate.field).string.min_len = 1`.
+func BufValidateOption(rule string) FieldOption {
+	return fieldOption("(buf.validate.field)." + rule)
+}
+
+// bufValidateRules converts the validate struct tag of a field of type typ to
+// protovalidate rules. The rules are mapped as follows, where <type> is the proto
+// scalar type of the field:
+//
+//	required     required = true
+//	min=N        <type>.gte = N, string.min_len = N, bytes.min_len = N,
+//	             repeated.min_items = N or map.min_pairs = N
+//	max=N        <type>.lte = N, string.max_len = N, bytes.max_len = N,
+//	             repeated.max_items = N or map.max_pairs = N
+//	len=N        string.len = N or bytes.len = N
+//	gt=N         <type>.gt = N
+//	gte=N        <type>.gte = N
+//	lt=N         <type>.lt = N
+//	lte=N        <type>.lte = N
+//	email        string.email = true
+//	url, uri     string.uri = true
+//	uuid         string.uuid = true
+//	hostname     string.hostname = true
+//	ip           string.ip = true
+//
+// Other rules have no protovalidate counterpart and are skipped with a warning.
+func (c *converter) bufValidateRules(structTag, typ string) []FieldOption {
+	tag, ok := reflect.StructTag(structTag).Lookup(validateTagKey)
+	if !ok {
+		return nil
+	}
+	scalar := strings.TrimPrefix(typ, pbOptional+fieldSep)
+	var collection string
+	switch {
+	case strings.HasPrefix(typ, pbArray+fieldSep):
+		collection = "repeated"
+	case strings.HasPrefix(typ, pbMap+"<"):
+		collection = "map"
+	}
+	numeric := scalarTypes[scalar] && scalar != pbString && scalar != pbBytes && scalar != pbBool
+
+	var opts []FieldOption
+	for _, rule := range strings.Split(tag, ",") {
+		kv := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+		name, value := kv[0], ""
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		var r string
+		switch {
+		case len(name) == 0, name == "omitempty":
+			continue
+		case name == "required":
+			r = "required = true"
+		case (name == "min" || name == "max") && collection == "repeated":
+			r = fmt.Sprintf("repeated.%s_items = %s", name, value)
+		case (name == "min" || name == "max") && collection == "map":
+			r = fmt.Sprintf("map.%s_pairs = %s", name, value)
+		case (name == "min" || name == "max" || name == "len") && (scalar == pbString || scalar == pbBytes) && len(collection) == 0:
+			if name == "len" {
+				r = fmt.Sprintf("%s.len = %s", scalar, value)
+			} else {
+				r = fmt.Sprintf("%s.%s_len = %s", scalar, name, value)
+			}
+		case name == "min" && numeric && len(collection) == 0:
+			r = fmt.Sprintf("%s.gte = %s", scalar, value)
+		case name == "max" && numeric && len(collection) == 0:
+			r = fmt.Sprintf("%s.lte = %s", scalar, value)
+		case (name == "gt" || name == "gte" || name == "lt" || name == "lte") && numeric && len(collection) == 0:
+			r = fmt.Sprintf("%s.%s = %s", scalar, name, value)
+		case scalar == pbString && len(collection) == 0:
+			switch name {
+			case "email", "uuid", "hostname", "ip":
+				r = fmt.Sprintf("string.%s = true", name)
+			case "url", "uri":
+				r = "string.uri = true"
+			}
+		}
+		if len(r) == 0 {
+			c.warn("validate rule %s has no protovalidate counterpart for %s", strings.TrimSpace(rule), typ)
+			continue
+		}
+		opts = append(opts, BufValidateOption(r))
+	}
+	if len(opts) > 0 {
+		c.addImport(bufValidateImport)
+	}
+	return opts
+}
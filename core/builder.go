@@ -0,0 +1,159 @@
+package core
+
+import "fmt"
+
+// ProtoFileBuilder builds a ProtoFile declaration by declaration, validating the
+// whole file in Build:
+//
+//	file, err := core.NewProtoFile(core.Proto3, "example.service").
+//		AddMessage(user).
+//		AddEnum(status).
+//		Build()
+type ProtoFileBuilder struct {
+	file ProtoFile
+}
+
+// NewProtoFile starts building a file with the syntax and package.
+func NewProtoFile(syntax SyntaxVersion, pkg string) *ProtoFileBuilder {
+	return &ProtoFileBuilder{file: ProtoFile{Syntax: syntax, Package: pkg}}
+}
+
+// AddMessage adds a top-level message.
+func (b *ProtoFileBuilder) AddMessage(m Message) *ProtoFileBuilder {
+	b.file.Messages = append(b.file.Messages, m)
+	return b
+}
+
+// AddEnum adds a top-level enum.
+func (b *ProtoFileBuilder) AddEnum(e Enum) *ProtoFileBuilder {
+	b.file.Enums = append(b.file.Enums, e)
+	return b
+}
+
+// AddService adds a service, importing the proto files its RPC options require
+// as ProtoFile.AddService does.
+func (b *ProtoFileBuilder) AddService(s Service) *ProtoFileBuilder {
+	b.file.AddService(s)
+	return b
+}
+
+// AddImport adds an import.
+func (b *ProtoFileBuilder) AddImport(path string) *ProtoFileBuilder {
+	b.file.addImport(path)
+	return b
+}
+
+// SetOption sets a file option, e.g. SetOption("go_package", `"example/service"`).
+func (b *ProtoFileBuilder) SetOption(name, value string) *ProtoFileBuilder {
+	if b.file.Options == nil {
+		b.file.Options = make(map[string]string)
+	}
+	b.file.Options[name] = value
+	return b
+}
+
+// Build validates the file and returns it: the top-level names must be unique,
+// the field types declared or imported, and the declarations valid as checked by
+// ProtoFile.Validate. The file is a copy, so the builder can go on without
+// changing it; use ToBuilder to derive another file from it.
+func (b *ProtoFileBuilder) Build() (*ProtoFile, error) {
+	file := b.file.clone()
+	if err := file.validateReferences(); err != nil {
+		return nil, err
+	}
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ToBuilder returns a builder starting from a copy of the file.
+func (f ProtoFile) ToBuilder() *ProtoFileBuilder {
+	return &ProtoFileBuilder{file: f.clone()}
+}
+
+// clone copies the declarations of the file, so that changing them does not
+// affect f.
+func (f ProtoFile) clone() ProtoFile {
+	c := f
+	c.Imports = append([]string(nil), f.Imports...)
+	c.WeakImports = append([]string(nil), f.WeakImports...)
+	if f.Options != nil {
+		c.Options = make(map[string]string, len(f.Options))
+		for name, value := range f.Options {
+			c.Options[name] = value
+		}
+	}
+	c.Extends = make([]Extend, len(f.Extends))
+	for i, e := range f.Extends {
+		e.Fields = cloneFields(e.Fields)
+		c.Extends[i] = e
+	}
+	c.Messages = make([]Message, len(f.Messages))
+	for i, m := range f.Messages {
+		c.Messages[i] = m.CloneWithOpts(CloneOptions{DeepCloneNested: true})
+	}
+	c.Enums = make([]Enum, len(f.Enums))
+	for i, e := range f.Enums {
+		e.Values = append([]EnumValue(nil), e.Values...)
+		c.Enums[i] = e
+	}
+	c.Services = make([]Service, len(f.Services))
+	for i, s := range f.Services {
+		s.RPCs = append([]RPC(nil), s.RPCs...)
+		c.Services[i] = s
+	}
+	return c
+}
+
+// validateReferences checks that the names of the top-level declarations are
+// unique and that the types of the message fields are declared or imported.
+func (f ProtoFile) validateReferences() error {
+	kinds := make(map[string]string)
+	declare := func(kind, name string) error {
+		if existing, ok := kinds[name]; ok {
+			return fmt.Errorf("%s %s conflicts with %s %s", kind, name, existing, name)
+		}
+		kinds[name] = kind
+		return nil
+	}
+	for _, m := range f.Messages {
+		if err := declare("message", m.Name); err != nil {
+			return err
+		}
+	}
+	for _, e := range f.Enums {
+		if err := declare("enum", e.Name); err != nil {
+			return err
+		}
+	}
+	for _, s := range f.Services {
+		if err := declare("service", s.Name); err != nil {
+			return err
+		}
+	}
+
+	scope := newTypeScope(&f, true)
+	var check func(path string, m Message) error
+	check = func(path string, m Message) error {
+		for _, field := range m.allFields() {
+			typ := field.ProtoType()
+			if scalarTypes[typ] || typ == pbAny || scope.resolvesFrom(path, typ) {
+				continue
+			}
+			return fmt.Errorf("message %s: field %s has undeclared type %s", path, field.Name, typ)
+		}
+		for _, nested := range m.NestedMessages {
+			if err := check(path+"."+nested.Name, nested); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, m := range f.Messages {
+		if err := check(m.Name, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package core
+
+// CloneOptions controls how Message.CloneWithOpts copies a message.
+type CloneOptions struct {
+	// DeepCloneNested clones the nested messages recursively. Otherwise the clone
+	// shares them with the original.
+	DeepCloneNested bool
+	// ResetTags renumbers the fields of the clone from 1 in order, followed by the
+	// oneof fields.
+	ResetTags bool
+}
+
+// Clone returns a copy of the message whose fields, oneofs, options and reserved
+// declarations can be changed without affecting m, e.g. to derive the messages of
+// several API versions from a template. Nested messages are shared with m.
+func (m Message) Clone() Message {
+	return m.CloneWithOpts(CloneOptions{})
+}
+
+// CloneWithOpts is like Clone, copying the nested messages and renumbering the
+// fields as set by opts.
+func (m Message) CloneWithOpts(opts CloneOptions) Message {
+	c := m
+	c.Fields = cloneFields(m.Fields)
+	if m.Oneofs != nil {
+		c.Oneofs = make([]Oneof, len(m.Oneofs))
+		for i, o := range m.Oneofs {
+			o.Fields = cloneFields(o.Fields)
+			c.Oneofs[i] = o
+		}
+	}
+	if m.Options != nil {
+		c.Options = make(map[string]string, len(m.Options))
+		for name, value := range m.Options {
+			c.Options[name] = value
+		}
+	}
+	c.ReservedTags = append([]int(nil), m.ReservedTags...)
+	c.ReservedTagRanges = append([][2]int(nil), m.ReservedTagRanges...)
+	c.ReservedNames = append([]string(nil), m.ReservedNames...)
+	if opts.DeepCloneNested && m.NestedMessages != nil {
+		c.NestedMessages = make([]Message, len(m.NestedMessages))
+		for i, nested := range m.NestedMessages {
+			c.NestedMessages[i] = nested.CloneWithOpts(opts)
+		}
+	}
+	if opts.ResetTags {
+		c.renumberFields()
+	}
+	return c
+}
+
+// cloneFields copies fields together with their options.
+func cloneFields(fields []MessageField) []MessageField {
+	if fields == nil {
+		return nil
+	}
+	clone := make([]MessageField, len(fields))
+	for i, f := range fields {
+		f.options = append([]FieldOption(nil), f.options...)
+		clone[i] = f
+	}
+	return clone
+}
@@ -0,0 +1,118 @@
+package core
+
+import "fmt"
+
+// BreakingKind is the kind of a breaking change between two versions of a message.
+type BreakingKind int
+
+const (
+	// TagChanged means a field kept its name but got another tag.
+	TagChanged BreakingKind = iota
+	// TypeChanged means a field kept its name but got another type.
+	TypeChanged
+	// FieldRemoved means a field of the old message is missing in the new one.
+	FieldRemoved
+	// FieldReordered means a field kept its name and tag but moved relative to
+	// the other fields, which usually hints at tags assigned by position.
+	FieldReordered
+)
+
+// String returns the name of a BreakingKind.
+func (k BreakingKind) String() string {
+	switch k {
+	case TagChanged:
+		return "TagChanged"
+	case TypeChanged:
+		return "TypeChanged"
+	case FieldRemoved:
+		return "FieldRemoved"
+	case FieldReordered:
+		return "FieldReordered"
+	default:
+		return "Unknown"
+	}
+}
+
+// BreakingChange describes a change of a field that breaks the compatibility
+// between two versions of a message. NewTag and NewType are zero for removed fields.
+type BreakingChange struct {
+	FieldName        string
+	OldTag           int
+	NewTag           int
+	OldType, NewType string
+	Kind             BreakingKind
+}
+
+// CompareMessages returns the breaking changes from the old to the new version of
+// a message. Fields are matched by name, so it returns an error if a version has
+// duplicate field names.
+func CompareMessages(old, new Message) ([]BreakingChange, error) {
+	for _, m := range []Message{old, new} {
+		if m.HasDuplicateNames() {
+			return nil, fmt.Errorf("message %s: duplicate field names", m.Name)
+		}
+	}
+	newFields := make(map[string]MessageField)
+	for _, f := range new.allFields() {
+		newFields[f.Name] = f
+	}
+
+	var (
+		changes []BreakingChange
+		// 新旧版本共有字段的相对顺序
+		oldOrder []string
+	)
+	for _, f := range old.allFields() {
+		nf, ok := newFields[f.Name]
+		if !ok {
+			changes = append(changes, BreakingChange{
+				FieldName: f.Name,
+				OldTag:    f.Tag(),
+				OldType:   f.Typ,
+				Kind:      FieldRemoved,
+			})
+			continue
+		}
+		oldOrder = append(oldOrder, f.Name)
+		change := BreakingChange{
+			FieldName: f.Name,
+			OldTag:    f.Tag(),
+			NewTag:    nf.Tag(),
+			OldType:   f.Typ,
+			NewType:   nf.Typ,
+		}
+		if f.Tag() != nf.Tag() {
+			change.Kind = TagChanged
+			changes = append(changes, change)
+		}
+		if f.Typ != nf.Typ {
+			change.Kind = TypeChanged
+			changes = append(changes, change)
+		}
+	}
+
+	oldFields := make(map[string]MessageField)
+	for _, f := range old.allFields() {
+		oldFields[f.Name] = f
+	}
+	var newOrder []string
+	for _, f := range new.allFields() {
+		if _, ok := oldFields[f.Name]; ok {
+			newOrder = append(newOrder, f.Name)
+		}
+	}
+	for i, name := range oldOrder {
+		of, nf := oldFields[name], newFields[name]
+		if newOrder[i] != name && of.Tag() == nf.Tag() {
+			changes = append(changes, BreakingChange{
+				FieldName: name,
+				OldTag:    of.Tag(),
+				NewTag:    nf.Tag(),
+				OldType:   of.Typ,
+				NewType:   nf.Typ,
+				Kind:      FieldReordered,
+			})
+		}
+	}
+	return changes, nil
+}
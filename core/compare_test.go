@@ -0,0 +1,52 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareMessages(t *testing.T) {
+	old := Message{Name: "User", Fields: []MessageField{
+		NewMessageField("string", "name", 1, ""),
+		NewMessageField("int32", "age", 2, ""),
+		NewMessageField("string", "email", 3, ""),
+		NewMessageField("bool", "active", 4, ""),
+	}}
+	new := Message{Name: "User", Fields: []MessageField{
+		NewMessageField("int64", "age", 2, ""),
+		NewMessageField("string", "name", 1, ""),
+		NewMessageField("bool", "active", 5, ""),
+	}}
+	changes, err := CompareMessages(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BreakingChange{
+		{FieldName: "age", OldTag: 2, NewTag: 2, OldType: "int32", NewType: "int64", Kind: TypeChanged},
+		{FieldName: "email", OldTag: 3, OldType: "string", Kind: FieldRemoved},
+		{FieldName: "active", OldTag: 4, NewTag: 5, OldType: "bool", NewType: "bool", Kind: TagChanged},
+		{FieldName: "name", OldTag: 1, NewTag: 1, OldType: "string", NewType: "string", Kind: FieldReordered},
+		{FieldName: "age", OldTag: 2, NewTag: 2, OldType: "int32", NewType: "int64", Kind: FieldReordered},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %+v\nwant %+v", changes, want)
+	}
+}
+
+func TestCompareMessagesDuplicateNames(t *testing.T) {
+	unique := Message{Name: "User", Fields: []MessageField{
+		NewMessageField("string", "name", 1, ""),
+		NewMessageField("string", "email", 2, ""),
+	}}
+	duplicate := Message{Name: "User", Fields: []MessageField{
+		NewMessageField("string", "name", 1, ""),
+		NewMessageField("string", "email", 2, ""),
+		NewMessageField("string", "name", 3, ""),
+	}}
+	if _, err := CompareMessages(duplicate, unique); err == nil {
+		t.Error("duplicate names in the old message accepted")
+	}
+	if _, err := CompareMessages(unique, duplicate); err == nil {
+		t.Error("duplicate names in the new message accepted")
+	}
+}
@@ -0,0 +1,24 @@
+package core
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// compileProto builds the descriptor of file and resolves it against the well-known
+// types registered by output.go, failing the test if it does not compile.
+func compileProto(t *testing.T, file *ProtoFile) protoreflect.FileDescriptor {
+	t.Helper()
+	fd, err := file.ToFileDescriptorProto("test.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := protodesc.NewFile(fd, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, file)
+	}
+	return desc
+}
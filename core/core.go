@@ -2,12 +2,14 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"io"
-	"os/exec"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 const (
@@ -15,10 +17,13 @@ const (
 	// two spaces
 	indent = "  "
 
-	structStart = "type"
-	structEnd   = "}"
-	fieldSep    = " "
-	commentSep  = "//"
+	fieldSep = " "
+
+	// truncatedComment is the comment of fields whose struct exceeds the max depth
+	truncatedComment = "truncated: max depth exceeded"
+
+	// messageOptionPrefix starts a struct comment line that sets custom message options
+	messageOptionPrefix = "pb-option:"
 )
 
 // MessageField represents the field of a message.
@@ -26,288 +31,1470 @@ type MessageField struct {
 	Typ     string
 	Name    string
 	tag     int
+	tagSet  bool
 	Comment string
+	options []FieldOption
 }
 
 // NewMessageField creates a new message field.
 func NewMessageField(typ, name string, tag int, comment string) MessageField {
-	return MessageField{typ, name, tag, comment}
+	return MessageField{Typ: typ, Name: name, tag: tag, tagSet: true, Comment: comment}
+}
+
+// NewMessageFieldWithOptions creates a new message field with field options.
+func NewMessageFieldWithOptions(typ, name string, tag int, comment string, opts ...FieldOption) MessageField {
+	f := NewMessageField(typ, name, tag, comment)
+	f.options = opts
+	return f
+}
+
+// WithComment returns a copy of the message field with the given comment.
+func (f MessageField) WithComment(comment string) MessageField {
+	f.Comment = comment
+	return f
+}
+
+// WithTag returns a copy of the message field with the given tag.
+func (f MessageField) WithTag(tag int) MessageField {
+	f.tag = tag
+	f.tagSet = true
+	return f
+}
+
+// WithType returns a copy of the message field with the given type.
+func (f MessageField) WithType(typ string) MessageField {
+	f.Typ = typ
+	return f
 }
 
-// Tag returns the unique numbered tag of the message field.
+// Tag returns the unique numbered tag of the message field, or 0 if it is unset.
 func (f MessageField) Tag() int {
-	return f.tag
+	tag, _ := f.TypedTag()
+	return tag
+}
+
+// TypedTag returns the tag of the message field and whether it was set, which
+// distinguishes an unset tag from a zero tag.
+func (f MessageField) TypedTag() (tag int, isSet bool) {
+	if !f.tagSet {
+		return 0, false
+	}
+	return f.tag, true
+}
+
+// Options returns the field options of the message field.
+func (f MessageField) Options() []FieldOption {
+	return f.options
 }
 
 // String returns a string representation of a message field.
 func (f MessageField) String() string {
-	return fmt.Sprintf("%s %s = %d", f.Typ, f.Name, f.tag)
+	if len(f.options) == 0 {
+		return fmt.Sprintf("%s %s = %d", f.Typ, f.Name, f.tag)
+	}
+	opts := make([]string, 0, len(f.options))
+	for _, opt := range f.options {
+		opts = append(opts, opt.protoOption())
+	}
+	return fmt.Sprintf("%s %s = %d [%s]", f.Typ, f.Name, f.tag, strings.Join(opts, ", "))
+}
+
+// StringWithLeadingComment returns a string representation of a message field,
+// preceded by its comment on its own line.
+func (f MessageField) StringWithLeadingComment() string {
+	if len(f.Comment) == 0 {
+		return f.String()
+	}
+	return fmt.Sprintf("// %s\n%s", f.Comment, f)
+}
+
+// line renders the declaration of the message field in a message body, prefixed
+// by prefix, with its comment in the given style.
+func (f MessageField) line(prefix string, style CommentStyle) string {
+	switch {
+	case len(f.Comment) == 0:
+		return fmt.Sprintf("%s%s;\n", prefix, f)
+	case style == CommentStyleLeading:
+		return fmt.Sprintf("%s// %s\n%s%s;\n", prefix, f.Comment, prefix, f)
+	default:
+		return fmt.Sprintf("%s%s; // %s\n", prefix, f, f.Comment)
+	}
+}
+
+// Signature returns the type and name of the message field. Unlike String it does
+// not depend on the tag, so it can be used to compare fields.
+func (f MessageField) Signature() string {
+	return f.Typ + fieldSep + f.Name
+}
+
+// IsRepeated reports whether the field is a repeated field.
+func (f MessageField) IsRepeated() bool {
+	return strings.HasPrefix(f.Typ, pbArray+fieldSep)
+}
+
+// IsMap reports whether the field is a map field.
+func (f MessageField) IsMap() bool {
+	return strings.HasPrefix(f.Typ, pbMap+"<")
+}
+
+// ScalarType returns the element type of the field, without the repeated label.
+func (f MessageField) ScalarType() string {
+	return strings.TrimPrefix(f.Typ, pbArray+fieldSep)
+}
+
+// ProtoType returns the base type of the field, e.g. User for `repeated User`,
+// `optional User` and `map<string, User>`.
+func (f MessageField) ProtoType() string {
+	if _, value, ok := parseMapType(f.Typ); ok {
+		return value
+	}
+	return strings.TrimPrefix(f.ScalarType(), pbOptional+fieldSep)
+}
+
+// MapKeyType returns the key type of a map field, e.g. string for
+// `map<string, User>`, or an empty string if f is not a map field.
+func (f MessageField) MapKeyType() string {
+	key, _, _ := parseMapType(f.Typ)
+	return key
 }
 
 // Message represents a protocol buffer message.
 type Message struct {
 	Name    string
 	Comment string
+	Options map[string]string
 	Fields  []MessageField
+	Oneofs  []Oneof
+	// NestedMessages are the messages declared inside the message.
+	NestedMessages []Message
+	// ReservedTags, ReservedTagRanges (both ends included) and ReservedNames may
+	// not be used by fields, e.g. those of removed fields.
+	ReservedTags      []int
+	ReservedTagRanges [][2]int
+	ReservedNames     []string
+	// MessageSetWireFormat marks a legacy proto2 MessageSet with the
+	// message_set_wire_format option.
+	MessageSetWireFormat bool
+}
+
+// Signature returns the sorted signatures of the message fields, one per line.
+func (m Message) Signature() string {
+	signatures := make([]string, 0, len(m.Fields))
+	for _, f := range m.Fields {
+		signatures = append(signatures, f.Signature())
+	}
+	sort.Strings(signatures)
+	return strings.Join(signatures, "\n")
+}
+
+// allFields returns the fields of the message, including those of its oneofs.
+func (m Message) allFields() []MessageField {
+	fields := m.Fields
+	for _, o := range m.Oneofs {
+		fields = append(fields[:len(fields):len(fields)], o.Fields...)
+	}
+	return fields
+}
+
+// FieldCount returns the number of fields of the message, not counting the fields
+// of its oneofs.
+func (m Message) FieldCount() int {
+	return len(m.Fields)
+}
+
+// TotalFieldCount returns the number of fields of the message and of its nested
+// messages, recursively.
+func (m Message) TotalFieldCount() int {
+	count := m.FieldCount()
+	for _, nested := range m.NestedMessages {
+		count += nested.TotalFieldCount()
+	}
+	return count
+}
+
+// hasNestedMessage reports whether the message declares a nested message named name.
+func (m Message) hasNestedMessage(name string) bool {
+	for _, nested := range m.NestedMessages {
+		if nested.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasDuplicateTags reports whether two fields of the message share a tag.
+func (m Message) HasDuplicateTags() bool {
+	tags := make(map[int]bool, len(m.Fields))
+	for _, f := range m.allFields() {
+		if tags[f.tag] {
+			return true
+		}
+		tags[f.tag] = true
+	}
+	return false
+}
+
+// HasDuplicateNames reports whether two fields of the message share a name.
+func (m Message) HasDuplicateNames() bool {
+	names := make(map[string]bool, len(m.Fields))
+	for _, f := range m.allFields() {
+		if names[f.Name] {
+			return true
+		}
+		names[f.Name] = true
+	}
+	return false
+}
+
+// Validate checks that the message can be compiled as proto3.
+func (m Message) Validate() error {
+	return m.ValidateSyntax(Proto3)
+}
+
+// ValidateSyntax checks that the message can be compiled with the syntax or
+// edition. MessageSets are only supported by proto2.
+func (m Message) ValidateSyntax(v SyntaxVersion) error {
+	if m.MessageSetWireFormat && v != Proto2 {
+		return fmt.Errorf("message %s: message_set_wire_format requires proto2", m.Name)
+	}
+	if m.HasDuplicateTags() {
+		return fmt.Errorf("message %s: duplicate field tags", m.Name)
+	}
+	if m.HasDuplicateNames() {
+		return fmt.Errorf("message %s: duplicate field names", m.Name)
+	}
+	for _, f := range m.allFields() {
+		if err := validateTag(f.Tag()); err != nil {
+			return fmt.Errorf("message %s: field %s: %w", m.Name, f.Name, err)
+		}
+		if m.isReservedTag(f.Tag()) {
+			return fmt.Errorf("message %s: field %s uses reserved tag %d", m.Name, f.Name, f.Tag())
+		}
+		for _, name := range m.ReservedNames {
+			if f.Name == name {
+				return fmt.Errorf("message %s: field %s uses a reserved name", m.Name, f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// isReservedTag reports whether tag is reserved by the message.
+func (m Message) isReservedTag(tag int) bool {
+	for _, t := range m.ReservedTags {
+		if t == tag {
+			return true
+		}
+	}
+	for _, r := range m.ReservedTagRanges {
+		if tag >= r[0] && tag <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// ReorderFields orders the fields as listed in names, followed by the unlisted
+// fields in their original order, then renumbers the tags sequentially from 1.
+// Oneof fields are numbered after the other fields.
+func (m *Message) ReorderFields(names []string) error {
+	byName := make(map[string]MessageField, len(m.Fields))
+	for _, f := range m.Fields {
+		byName[f.Name] = f
+	}
+	fields := make([]MessageField, 0, len(m.Fields))
+	listed := make(map[string]bool, len(names))
+	for _, name := range names {
+		f, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("message %s: no field %s", m.Name, name)
+		}
+		if listed[name] {
+			return fmt.Errorf("message %s: field %s listed twice", m.Name, name)
+		}
+		listed[name] = true
+		fields = append(fields, f)
+	}
+	for _, f := range m.Fields {
+		if !listed[f.Name] {
+			fields = append(fields, f)
+		}
+	}
+
+	tag := 1
+	for i := range fields {
+		fields[i] = fields[i].WithTag(tag)
+		tag++
+	}
+	for i := range m.Oneofs {
+		for j := range m.Oneofs[i].Fields {
+			m.Oneofs[i].Fields[j] = m.Oneofs[i].Fields[j].WithTag(tag)
+			tag++
+		}
+	}
+	m.Fields = fields
+	return nil
+}
+
+// renumberFields numbers the fields from 1 in order, followed by the oneof fields.
+func (m *Message) renumberFields() {
+	tag := 1
+	for i := range m.Fields {
+		m.Fields[i] = m.Fields[i].WithTag(tag)
+		tag++
+	}
+	for i := range m.Oneofs {
+		for j := range m.Oneofs[i].Fields {
+			m.Oneofs[i].Fields[j] = m.Oneofs[i].Fields[j].WithTag(tag)
+			tag++
+		}
+	}
+}
+
+// SetOption sets a message option, rendered as `option <key> = <value>;` with the
+// value used verbatim.
+func (m *Message) SetOption(key, value string) {
+	if m.Options == nil {
+		m.Options = make(map[string]string)
+	}
+	m.Options[key] = value
+}
+
+// RemoveOption removes a message option.
+func (m *Message) RemoveOption(key string) {
+	delete(m.Options, key)
+}
+
+// SetDeprecated sets or removes the deprecated option of the message.
+func (m *Message) SetDeprecated(deprecated bool) {
+	if deprecated {
+		m.SetOption("deprecated", "true")
+	} else {
+		m.RemoveOption("deprecated")
+	}
+}
+
+// IsMapEntry reports whether the message is the entry of a map field, as marked
+// by the map_entry option.
+func (m Message) IsMapEntry() bool {
+	return m.Options["map_entry"] == "true"
+}
+
+// MergeFrom adds the fields of other whose name is not used by a field of m,
+// numbering them after the largest tag of m. It returns an error, leaving m
+// unchanged, if fields of both messages share a name but not a type. Oneofs and
+// nested messages of other are not merged.
+func (m *Message) MergeFrom(other Message) error {
+	byName := make(map[string]MessageField)
+	maxTag := 0
+	for _, f := range m.allFields() {
+		byName[f.Name] = f
+		if f.Tag() > maxTag {
+			maxTag = f.Tag()
+		}
+	}
+	var added []MessageField
+	for _, f := range other.Fields {
+		existing, ok := byName[f.Name]
+		if !ok {
+			maxTag++
+			added = append(added, f.WithTag(maxTag))
+			continue
+		}
+		if existing.Typ != f.Typ {
+			return fmt.Errorf("field %s has type %s in message %s and %s in message %s", f.Name, existing.Typ, m.Name, f.Typ, other.Name)
+		}
+	}
+	m.Fields = append(m.Fields, added...)
+	return nil
 }
 
 // String returns a string representation of a Message.
 func (m Message) String() string {
+	return m.stringAtDepth(0, defaultRenderStyle)
+}
+
+// stringAtDepth renders the message indented depth times by style.indent. Nested
+// messages are rendered at depth+1. The body declares the reserved tags and names,
+// then the options (message_set_wire_format first, the others sorted by name), the
+// fields, the oneofs and the nested messages.
+func (m Message) stringAtDepth(depth int, style renderStyle) string {
 	var buf bytes.Buffer
+	outer := strings.Repeat(style.indent, depth)
+	inner := outer + style.indent
 
+	// 多行注释逐行输出
 	if len(m.Comment) > 0 {
-		buf.WriteString(fmt.Sprintf("// %s\n", m.Comment))
+		for _, line := range strings.Split(m.Comment, "\n") {
+			buf.WriteString(strings.TrimRight(fmt.Sprintf("%s// %s", outer, line), " \t\r") + "\n")
+		}
 	}
-	buf.WriteString(fmt.Sprintf("message %s {\n", m.Name))
-	for _, f := range m.Fields {
-		if len(f.Comment) > 0 {
-			buf.WriteString(fmt.Sprintf("%s%s; // %s\n", indent, f, f.Comment))
-		} else {
-			buf.WriteString(fmt.Sprintf("%s%s;\n", indent, f))
+	buf.WriteString(fmt.Sprintf("%smessage %s {\n", outer, m.Name))
+	if len(m.ReservedTags) > 0 || len(m.ReservedTagRanges) > 0 {
+		reserved := make([]string, 0, len(m.ReservedTags)+len(m.ReservedTagRanges))
+		for _, t := range m.ReservedTags {
+			reserved = append(reserved, strconv.Itoa(t))
 		}
-
+		for _, r := range m.ReservedTagRanges {
+			reserved = append(reserved, fmt.Sprintf("%d to %d", r[0], r[1]))
+		}
+		buf.WriteString(fmt.Sprintf("%sreserved %s;\n", inner, strings.Join(reserved, ", ")))
+	}
+	if len(m.ReservedNames) > 0 {
+		names := make([]string, 0, len(m.ReservedNames))
+		for _, name := range m.ReservedNames {
+			if style.bareReservedNames {
+				names = append(names, name)
+			} else {
+				names = append(names, strconv.Quote(name))
+			}
+		}
+		buf.WriteString(fmt.Sprintf("%sreserved %s;\n", inner, strings.Join(names, ", ")))
+	}
+	if m.MessageSetWireFormat {
+		buf.WriteString(inner + "option message_set_wire_format = true;\n")
+	}
+	optionNames := make([]string, 0, len(m.Options))
+	for name := range m.Options {
+		optionNames = append(optionNames, name)
+	}
+	sort.Strings(optionNames)
+	for _, name := range optionNames {
+		buf.WriteString(fmt.Sprintf("%soption %s = %s;\n", inner, name, m.Options[name]))
+	}
+	for _, f := range m.Fields {
+		buf.WriteString(f.line(inner, style.commentStyle))
+	}
+	for _, o := range m.Oneofs {
+		buf.WriteString(o.stringAtDepth(depth+1, style))
 	}
-	buf.WriteString("}\n")
+	for _, nested := range m.NestedMessages {
+		buf.WriteString(nested.stringAtDepth(depth+1, style))
+	}
+	buf.WriteString(outer + "}\n")
 
 	return buf.String()
 }
 
 var (
-	pbFloat64 = "double"
-	pbFloat32 = "float"
-	pbInt64   = "int64"
-	pbInt32   = "int32"
-	pbUint64  = "uint64"
-	pbUint32  = "uint32"
-	pbBool    = "bool"
-	pbString  = "string"
-	pbArray   = "repeated"
-	pbMap     = "map"
-	pbAny     = "Any"
+	pbFloat64  = "double"
+	pbFloat32  = "float"
+	pbInt64    = "int64"
+	pbInt32    = "int32"
+	pbUint64   = "uint64"
+	pbUint32   = "uint32"
+	pbBool     = "bool"
+	pbString   = "string"
+	pbBytes    = "bytes"
+	pbArray    = "repeated"
+	pbOptional = "optional"
+	pbMap      = "map"
+	pbAny      = "google.protobuf.Any"
+	// pbListValue is the type of slices of dynamic values
+	pbListValue = "google.protobuf.ListValue"
+	// pbNullValue is the type of JSON null
+	pbNullValue = "google.protobuf.NullValue"
+	// pbDuration is the type of time.Duration with well-known types
+	pbDuration = "google.protobuf.Duration"
 )
 
-func Structs2Pb(strictMode bool, beans ...interface{}) string {
-	var result string
+// wellKnownType describes a go type with a predefined proto type, such as a
+// protobuf well-known type. importPath is empty if no import is needed, and
+// comment, if any, is added to the comment of the fields of the type.
+type wellKnownType struct {
+	pbType     string
+	importPath string
+	comment    string
+}
+
+// wellKnownTypes maps the full name (package path and type name) of a go type to
+// its predefined proto type.
+var wellKnownTypes = map[string]wellKnownType{
+	"google.golang.org/protobuf/types/known/fieldmaskpb.FieldMask": {"google.protobuf.FieldMask", "google/protobuf/field_mask.proto", ""},
+
+	// database/sql 的可空类型
+	"database/sql.NullString":  {pbOptional + fieldSep + pbString, "", ""},
+	"database/sql.NullInt64":   {pbOptional + fieldSep + pbInt64, "", ""},
+	"database/sql.NullInt32":   {pbOptional + fieldSep + pbInt32, "", ""},
+	"database/sql.NullInt16":   {pbOptional + fieldSep + pbInt32, "", ""},
+	"database/sql.NullByte":    {pbOptional + fieldSep + pbUint32, "", ""},
+	"database/sql.NullFloat64": {pbOptional + fieldSep + pbFloat64, "", ""},
+	"database/sql.NullBool":    {pbOptional + fieldSep + pbBool, "", ""},
+	"database/sql.NullTime":    {pbOptional + fieldSep + "google.protobuf.Timestamp", "google/protobuf/timestamp.proto", ""},
+
+	// 十进制数以字符串表示，不损失精度
+	"github.com/shopspring/decimal.Decimal": {pbString, "", "Decimal encoded as string"},
+}
+
+// wrappersImport is the proto file declaring the wrapper types.
+const wrappersImport = "google/protobuf/wrappers.proto"
+
+// structImport is the proto file declaring the types of dynamic JSON values.
+const structImport = "google/protobuf/struct.proto"
+
+// durationImport is the proto file declaring google.protobuf.Duration.
+const durationImport = "google/protobuf/duration.proto"
+
+// anyImport is the proto file declaring google.protobuf.Any.
+const anyImport = "google/protobuf/any.proto"
+
+// wrapperTypes maps the kinds of scalar pointers to their wrapper types.
+var wrapperTypes = map[reflect.Kind]string{
+	reflect.Float64: "google.protobuf.DoubleValue",
+	reflect.Float32: "google.protobuf.FloatValue",
+	reflect.Int:     "google.protobuf.Int64Value",
+	reflect.Int64:   "google.protobuf.Int64Value",
+	reflect.Int32:   "google.protobuf.Int32Value",
+	reflect.Int16:   "google.protobuf.Int32Value",
+	reflect.Int8:    "google.protobuf.Int32Value",
+	reflect.Uint:    "google.protobuf.UInt64Value",
+	reflect.Uint64:  "google.protobuf.UInt64Value",
+	reflect.Uint32:  "google.protobuf.UInt32Value",
+	reflect.Uint16:  "google.protobuf.UInt32Value",
+	reflect.Uint8:   "google.protobuf.UInt32Value",
+	reflect.Bool:    "google.protobuf.BoolValue",
+	reflect.String:  "google.protobuf.StringValue",
+}
+
+// wrapperPackages are the go packages of the wrapper types, the current one and
+// the one of the older github.com/golang/protobuf module.
+var wrapperPackages = []string{
+	"google.golang.org/protobuf/types/known/wrapperspb",
+	"github.com/golang/protobuf/ptypes/wrappers",
+}
+
+func init() {
+	// 包装类型字段直接使用对应的包装类型
+	for _, pkg := range wrapperPackages {
+		for _, name := range []string{"DoubleValue", "FloatValue", "Int64Value", "UInt64Value",
+			"Int32Value", "UInt32Value", "BoolValue", "StringValue", "BytesValue"} {
+			wellKnownTypes[pkg+"."+name] = wellKnownType{"google.protobuf." + name, wrappersImport, ""}
+		}
+	}
+}
+
+// converter holds the state shared by a single conversion.
+type converter struct {
+	options
+	imports map[string]bool
+	// messageOptionTypes maps the custom message options in use to the proto type
+	// of their value.
+	messageOptionTypes map[string]string
+	// manifest records the field mappings, warnings the pending warnings of the
+	// field being converted.
+	manifest []manifestEntry
+	warnings []string
+	// typeDocs holds the documentation of the structs parsed from source, keyed by
+	// their full name, and docPackages the packages already parsed for it.
+	typeDocs    map[string]typeDoc
+	docPackages map[string]bool
+	// enumConsts holds the constants of the named types parsed from source, keyed by
+	// the full name of their type, and enumTypes the named integer types converted
+	// to enums, in order of first use.
+	enumConsts map[string][]enumConst
+	enumTypes  []goType
+}
+
+func newConverter(opts ...Option) *converter {
+	return &converter{
+		options:            newOptions(opts...),
+		imports:            make(map[string]bool),
+		messageOptionTypes: make(map[string]string),
+		typeDocs:           make(map[string]typeDoc),
+		docPackages:        make(map[string]bool),
+		enumConsts:         make(map[string][]enumConst),
+	}
+}
+
+// addImport records a proto file that the generated output depends on.
+func (c *converter) addImport(path string) {
+	c.imports[path] = true
+}
+
+// importList returns the recorded imports in a stable order.
+func (c *converter) importList() []string {
+	list := make([]string, 0, len(c.imports))
+	for path := range c.imports {
+		list = append(list, path)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Structs2Pb converts the structs to the text of a proto file. Options may be passed
+// among the beans, e.g. Structs2Pb(true, User{}, WithOutputFormat(OutputFormatJSONDescriptor))
+// encodes the file in another format, as Structs2PbOutput does.
+func Structs2Pb(strictMode bool, beans ...interface{}) (string, error) {
+	var (
+		structs []interface{}
+		opts    []Option
+	)
+	for _, bean := range beans {
+		if opt, ok := bean.(Option); ok {
+			opts = append(opts, opt)
+		} else {
+			structs = append(structs, bean)
+		}
+	}
+	opts = append(opts, WithStrictMode(strictMode))
+	if newOptions(opts...).outputFormat != OutputFormatTextProto {
+		data, err := Structs2PbOutput(structs, opts...)
+		return string(data), err
+	}
+	file, err := Structs2ProtoFile(structs, opts...)
+	if err != nil {
+		return "", err
+	}
+	return file.String(), nil
+}
+
+// Structs2PbOrPanic is like Structs2Pb but panics if the conversion fails.
+//
+// Deprecated: Structs2PbOrPanic only eases the migration from the panicking
+// Structs2Pb; use Structs2Pb and handle the returned error instead.
+func Structs2PbOrPanic(strictMode bool, beans ...interface{}) string {
+	result, err := Structs2Pb(strictMode, beans...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Structs2ProtoFile converts the structs to a ProtoFile.
+func Structs2ProtoFile(beans []interface{}, opts ...Option) (*ProtoFile, error) {
+	c := newConverter(opts...)
+	file := ProtoFile{importResolver: c.importResolver}
+	if len(c.fileOptions) > 0 {
+		file.Options = c.fileOptions
+	}
+	var pkgPath string
 	for i := range beans {
-		bean := beans[i]
 		// 获取结构体的反射类型对象
-		v := reflect.Indirect(reflect.ValueOf(bean))
-		vT := v.Type()
+		vT, skip, err := c.beanType(beans[i])
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			continue
+		}
+		if len(file.Messages) == 0 {
+			pkgPath = vT.PkgPath()
+		}
 
-		comment, fields := struct2PbField(vT, 1, strictMode)
-		message := Message{
-			Name:    vT.Name(),
-			Comment: comment,
-			Fields:  fields,
+		message, err := c.struct2PbField(context.Background(), reflectType{vT}, 1, 0)
+		if err != nil {
+			return nil, err
 		}
-		result += message.String() + string('\n')
+		file.Messages = append(file.Messages, message)
 	}
-	return result
+	if err := c.finishFile(&file, pkgPath); err != nil {
+		return nil, err
+	}
+	return &file, nil
 }
 
-func struct2PbField(t reflect.Type, index int, strictMode bool) (comment string, fields []MessageField) {
-	c, fieldMap, err := getStructComment(t)
+// Structs2PbMessages converts the structs to messages in the order of beans, for
+// callers to inspect or change them before rendering. Beans skipped by
+// NilStrategySkip have no message. The imports and declarations needed by the
+// messages are only kept by Structs2ProtoFile.
+func Structs2PbMessages(beans []interface{}, opts ...Option) ([]*Message, error) {
+	file, err := Structs2ProtoFile(beans, opts...)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	messages := make([]*Message, len(file.Messages))
+	for i := range file.Messages {
+		messages[i] = &file.Messages[i]
+	}
+	return messages, nil
+}
+
+// finishFile adds the package, declarations and imports collected during the
+// conversion to the file, and writes the manifest if requested. Unless set by
+// WithPackageName, the package is derived from pkgPath, the go package of the
+// first struct.
+func (c *converter) finishFile(file *ProtoFile, pkgPath string) error {
+	file.Syntax = c.syntax
+	if c.protoSyntaxComment {
+		file.Header = "Generated by struct2pb " + Version()
+	}
+	file.CommentStyle = c.commentStyle
+	if c.protoIndentWidthSet && (c.protoIndentWidth < 1 || c.protoIndentWidth > 8) {
+		return fmt.Errorf("invalid indent width %d: must be in [1, 8]", c.protoIndentWidth)
+	}
+	if len(c.protoIndent) == 0 || strings.Trim(c.protoIndent, " \t") != "" {
+		return fmt.Errorf("invalid indent %q: only spaces and tabs are allowed", c.protoIndent)
+	}
+	file.Indent = c.protoIndent
+	file.Package = c.packageName
+	if len(file.Package) == 0 {
+		file.Package = GoPackagePath2ProtoPackage(pkgPath)
+	}
+	extend, err := c.messageOptionsExtend(file.Package)
+	if err != nil {
+		return err
+	}
+	if len(extend.Fields) > 0 {
+		c.addImport("google/protobuf/descriptor.proto")
+		file.Extends = append(file.Extends, extend)
+	}
+	for _, t := range c.enumTypes {
+		e, err := c.enumDecl(context.Background(), t)
+		if err != nil {
+			return err
+		}
+		file.Enums = append(file.Enums, e)
+	}
+	if c.syntax == Edition2023 && file.usesGoFeatures() {
+		c.addImport(goFeaturesImport)
+	}
+	file.Imports = c.importList()
+	// 弱导入不再作为普通导入
+	for _, path := range c.weakImports {
+		for i, imp := range file.Imports {
+			if imp == path {
+				file.Imports = append(file.Imports[:i], file.Imports[i+1:]...)
+				break
+			}
+		}
+		file.addWeakImport(path)
+	}
+	if c.manifestWriter != nil {
+		return c.writeManifest(c.manifestWriter)
+	}
+	return nil
+}
+
+// Struct2PbWithContext converts a struct to a Message. If ctx is done before the
+// sources are parsed for the comments, the error of ctx is returned. The message
+// is nil if the bean is skipped by NilStrategySkip.
+func Struct2PbWithContext(ctx context.Context, bean interface{}, opts ...Option) (*Message, error) {
+	c := newConverter(opts...)
+	t, skip, err := c.beanType(bean)
+	if err != nil || skip {
+		return nil, err
+	}
+	message, err := c.struct2PbField(ctx, reflectType{t}, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// Struct2PbRaw converts the struct type t to a Message, for callers that already
+// have its type. It returns ErrNotAStruct if t is not a struct.
+func Struct2PbRaw(t reflect.Type, opts ...Option) (*Message, error) {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%v: %w", t, ErrNotAStruct)
+	}
+	c := newConverter(opts...)
+	message, err := c.struct2PbField(context.Background(), reflectType{t}, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// NewMessageFieldFromStructField converts a struct field to a message field, for
+// callers building their own converters. The field number is tag unless the tag
+// scheme set by WithTagNumbering numbers it otherwise. Comments are only set by
+// WithFieldComment, since sf alone does not locate its source, and interface
+// fields are converted to Any.
+func NewMessageFieldFromStructField(sf reflect.StructField, tag int, opts ...Option) (MessageField, error) {
+	c := newConverter(opts...)
+	name := c.camelLower(sf.Name)
+	var comment string
+	if c.fieldComment != nil {
+		comment = sanitizeComment(c.fieldComment(sf))
+	}
+	if sf.Type.Kind() == reflect.Interface {
+		comment, err := c.interfaceComment(sf.Type.String(), comment)
+		if err != nil {
+			return MessageField{}, err
+		}
+		return c.newField(pbAny, name, string(sf.Tag), tag, comment)
+	}
+	pbType, err := c.goType2PbType(reflectType{sf.Type})
+	if err != nil {
+		return MessageField{}, fmt.Errorf("%s: %w", sf.Name, err)
+	}
+	return c.newField(pbType, name, string(sf.Tag), tag, comment)
+}
+
+// struct2PbField converts the fields of a struct, numbering them from index.
+func (c *converter) struct2PbField(ctx context.Context, t goType, index, depth int) (Message, error) {
+	var (
+		comment     string
+		fieldMap    map[string]string
+		optionLines []string
+		err         error
+	)
+	// 注释均由自定义函数提供时无需解析源码
+	if c.messageComment == nil || c.fieldComment == nil {
+		doc, err := c.structDoc(ctx, t)
+		if err != nil {
+			return Message{}, err
+		}
+		comment, fieldMap, optionLines = doc.comment, doc.fieldComments, doc.optionLines
+	}
+	// 自定义注释函数只用于反射类型，见checkTypesOptions
+	rt, _ := t.(reflectType)
+	if c.messageComment != nil {
+		comment = c.messageComment(rt.Type)
+	}
+	msg := Message{Name: t.Name(), Comment: sanitizeComment(comment)}
+	if msg.Options, err = c.parseMessageOptions(optionLines); err != nil {
+		return Message{}, err
+	}
+	structTags := make([]string, t.NumField())
+	for i := range structTags {
+		structTags[i] = t.Field(i).Tag
+	}
+	if err := c.addResource(&msg, structTags, depth); err != nil {
+		return Message{}, err
 	}
-	comment = c
 
 	for i := 0; i < t.NumField(); i++ {
 		fieldType := t.Field(i)
 		// 忽略未导出字段
-		if importable := len(fieldType.PkgPath) == 0; !importable {
+		if !fieldType.Exported {
 			continue
 		}
 		// 匿名字段
 		if fieldType.Anonymous {
-			_, newFields := struct2PbField(fieldType.Type.Elem(), index, strictMode)
-			index += len(newFields)
-			fields = append(fields, newFields...)
+			if depth >= c.maxDepth {
+				if c.strictMode {
+					return Message{}, fmt.Errorf("max depth exceeded: %s.%s", t.String(), fieldType.Name)
+				}
+				field, err := c.newField(pbAny, c.camelLower(fieldType.Name), fieldType.Tag, index, truncatedComment)
+				if err != nil {
+					return Message{}, err
+				}
+				msg.Fields = append(msg.Fields, field)
+				c.addImport(anyImport)
+				c.warn(truncatedComment)
+				c.record(t.String(), fieldType.Name, fieldType.Type.String(), pbAny)
+				index++
+				continue
+			}
+			embeddedType := fieldType.Type
+			if embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() != reflect.Struct {
+				return Message{}, fmt.Errorf("unsupported embedded type: %s", fieldType.Type.String())
+			}
+			embedded, err := c.struct2PbField(ctx, embeddedType, index, depth+1)
+			if err != nil {
+				return Message{}, err
+			}
+			index += len(embedded.allFields())
+			msg.Fields = append(msg.Fields, embedded.Fields...)
+			msg.Oneofs = append(msg.Oneofs, embedded.Oneofs...)
+			msg.NestedMessages = append(msg.NestedMessages, embedded.NestedMessages...)
 			continue
 		}
-		pbType := goType2PbType(fieldType.Type, strictMode)
-		fieldName := Camel2CamelLower(fieldType.Name)
+		fieldName := c.camelLower(fieldType.Name)
 		fieldComment := fieldMap[fieldType.Name]
-		fields = append(fields, NewMessageField(pbType, fieldName, index, fieldComment))
+		if c.fieldComment != nil {
+			fieldComment = c.fieldComment(rt.Type.Field(i))
+		}
+		fieldComment = sanitizeComment(fieldComment)
+		// 已注册实现类型的接口字段转换为oneof
+		if iface, implementors := c.oneofRegistry.implementors(fieldType.Type); len(implementors) > 0 {
+			oneof, err := c.interface2Oneof(iface, implementors, fieldName, index)
+			if err != nil {
+				return Message{}, err
+			}
+			oneof.Comment = fieldComment
+			msg.Oneofs = append(msg.Oneofs, oneof)
+			c.record(t.String(), fieldType.Name, fieldType.Type.String(), "oneof "+fieldName)
+			index += len(oneof.Fields)
+			continue
+		}
+		// oneof不能是repeated，已注册实现类型的接口切片使用包装消息
+		if fieldType.Type.Kind() == reflect.Slice {
+			if iface, implementors := c.oneofRegistry.implementors(fieldType.Type.Elem()); len(implementors) > 0 {
+				wrapper, err := c.interfaceSlice2Wrapper(iface, implementors)
+				if err != nil {
+					return Message{}, err
+				}
+				if !msg.hasNestedMessage(wrapper.Name) {
+					msg.NestedMessages = append(msg.NestedMessages, wrapper)
+				}
+				pbType := pbArray + fieldSep + wrapper.Name
+				field, err := c.newField(pbType, fieldName, fieldType.Tag, index, fieldComment)
+				if err != nil {
+					return Message{}, err
+				}
+				msg.Fields = append(msg.Fields, field)
+				c.record(t.String(), fieldType.Name, fieldType.Type.String(), pbType)
+				index++
+				continue
+			}
+		}
+		// 值为切片的map使用包装消息
+		if isSliceValueMap(fieldType.Type) {
+			wrapper, pbType, err := c.sliceMap2Wrapper(fieldType)
+			if err != nil {
+				return Message{}, err
+			}
+			msg.NestedMessages = append(msg.NestedMessages, wrapper)
+			field, err := c.newField(pbType, fieldName, fieldType.Tag, index, fieldComment)
+			if err != nil {
+				return Message{}, err
+			}
+			msg.Fields = append(msg.Fields, field)
+			c.record(t.String(), fieldType.Name, fieldType.Type.String(), pbType)
+			index++
+			continue
+		}
+		// 其他接口字段无法确定具体类型，使用Any
+		if fieldType.Type.Kind() == reflect.Interface {
+			comment, err := c.interfaceComment(fieldType.Type.String(), fieldComment)
+			if err != nil {
+				return Message{}, err
+			}
+			field, err := c.newField(pbAny, fieldName, fieldType.Tag, index, comment)
+			if err != nil {
+				return Message{}, err
+			}
+			msg.Fields = append(msg.Fields, field)
+			c.record(t.String(), fieldType.Name, fieldType.Type.String(), pbAny)
+			index++
+			continue
+		}
+		pbType, err := c.goType2PbType(fieldType.Type)
+		if err != nil {
+			return Message{}, err
+		}
+		if isSetType(fieldType.Type) {
+			fieldComment = goTypeComment(fieldComment, fieldType.Type.String(), "set")
+		}
+		if note := c.builtinTypeComment(fieldType.Type); len(note) > 0 {
+			fieldComment = appendComment(fieldComment, note)
+		}
+		field, err := c.newField(pbType, fieldName, fieldType.Tag, index, fieldComment)
+		if err != nil {
+			return Message{}, err
+		}
+		if err := c.appendField(&msg, field, fieldType.Tag, fieldType.Type.Kind() == reflect.Ptr); err != nil {
+			return Message{}, err
+		}
+		c.record(t.String(), fieldType.Name, fieldType.Type.String(), pbType)
 
 		index++
 	}
-	return
+	// 嵌入的结构体已合并，只在最外层排序和处理冲突
+	if depth == 0 {
+		if c.fieldOrderByCategory {
+			c.orderFieldsByCategory(&msg)
+		}
+		if err := c.resolveTagConflicts(&msg); err != nil {
+			return Message{}, err
+		}
+	}
+	return msg, nil
+}
+
+// interfaceComment returns the comment of an interface field converted to Any,
+// noting its go type since the concrete type is lost. Interface fields are
+// rejected in strict mode.
+func (c *converter) interfaceComment(goType, comment string) (string, error) {
+	if c.strictMode {
+		return "", fmt.Errorf("unsupported interface type: %s", goType)
+	}
+	c.warn("interface type %s converted to %s", goType, pbAny)
+	c.addImport(anyImport)
+	return goTypeComment(comment, goType, "interface"), nil
+}
+
+// goTypeComment appends a note of the go type of a field to its comment, for
+// fields whose proto type loses the kind of go type.
+func goTypeComment(comment, goType, kind string) string {
+	return appendComment(comment, fmt.Sprintf("Go type: %s (%s)", goType, kind))
+}
+
+// appendComment appends a note to a field comment.
+func appendComment(comment, note string) string {
+	if len(comment) == 0 {
+		return note
+	}
+	return comment + "; " + note
+}
+
+// builtinType returns the predefined proto type of the go type with the given
+// full name, unless disabled by WithoutBuiltinType.
+func (c *converter) builtinType(name string) (wellKnownType, bool) {
+	if c.disabledBuiltinTypes[name] {
+		return wellKnownType{}, false
+	}
+	wkt, ok := wellKnownTypes[name]
+	return wkt, ok
+}
+
+// builtinTypeComment returns the comment added to the fields of the go type t, or
+// of its elements, by its predefined proto type.
+func (c *converter) builtinTypeComment(t goType) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	wkt, _ := c.builtinType(fullName(t))
+	return wkt.comment
+}
+
+// parseMessageOptions parses the `pb-option: <name>=<value>, ...` lines of a struct
+// comment into message options. Values are used verbatim, except that bare words
+// which are not numbers or booleans are quoted.
+func (c *converter) parseMessageOptions(lines []string) (map[string]string, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	msgOptions := make(map[string]string)
+	for _, line := range lines {
+		for _, pair := range strings.Split(strings.TrimPrefix(line, messageOptionPrefix), ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid message option: %s", strings.TrimSpace(pair))
+			}
+			name, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+			_, declared := c.customMessageOptions[name]
+			if _, imported := c.messageOptionImports[name]; !declared && !imported {
+				if c.strictMode {
+					return nil, fmt.Errorf("unregistered message option: %s", name)
+				}
+				continue
+			}
+			typ := optionValueType(value)
+			if typ == pbString && !strings.HasPrefix(value, `"`) {
+				value = strconv.Quote(value)
+			}
+			if _, ok := c.messageOptionTypes[name]; !ok {
+				c.messageOptionTypes[name] = typ
+			}
+			msgOptions["("+name+")"] = value
+		}
+	}
+	return msgOptions, nil
+}
+
+// messageOptionsExtend declares the custom message options in use in the file of
+// package pkg. The options declared in other files are imported instead; the other
+// options must belong to pkg, since the extension fields are declared in it under
+// the name relative to it.
+func (c *converter) messageOptionsExtend(pkg string) (Extend, error) {
+	extend := Extend{Extendee: "google.protobuf.MessageOptions"}
+	for name, typ := range c.messageOptionTypes {
+		if path, ok := c.messageOptionImports[name]; ok {
+			c.addImport(path)
+			continue
+		}
+		var optionPkg, fieldName string
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			optionPkg, fieldName = name[:i], name[i+1:]
+		} else {
+			optionPkg, fieldName = pkg, name
+		}
+		if optionPkg != pkg {
+			return Extend{}, fmt.Errorf("message option %s is not in package %s: register its file with WithImportedMessageOption", name, pkg)
+		}
+		extend.Fields = append(extend.Fields, NewMessageField(typ, fieldName, c.customMessageOptions[name], ""))
+	}
+	sort.Slice(extend.Fields, func(i, j int) bool {
+		return extend.Fields[i].Tag() < extend.Fields[j].Tag()
+	})
+	return extend, nil
+}
+
+// optionValueType returns the proto type of an option value.
+func optionValueType(value string) string {
+	if value == "true" || value == "false" {
+		return pbBool
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return pbInt64
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return pbFloat64
+	}
+	return pbString
 }
 
 // goType2PbType go type to pb type
-func goType2PbType(t reflect.Type, strictMode bool) string {
+func (c *converter) goType2PbType(t goType) (string, error) {
 	// var cByteDefault byte
 	timeType := reflect.TypeOf(time.Time{})
 	// byteType := reflect.TypeOf(cByteDefault)
 	// bytesType := reflect.SliceOf(byteType)
+	if c.useWellKnownTypes && isDurationType(t) {
+		c.addImport(durationImport)
+		return pbDuration, nil
+	}
+	// 具名整数类型作为枚举
+	if isEnumType(t) {
+		c.addEnumType(t)
+		return t.Name(), nil
+	}
 	switch k := t.Kind(); k {
 	case reflect.Float64:
-		return pbFloat64
+		return pbFloat64, nil
 	case reflect.Float32:
-		return pbFloat32
+		return pbFloat32, nil
 
 	case reflect.Int:
 		fallthrough
 	case reflect.Int64:
-		return pbInt64
+		return pbInt64, nil
 	case reflect.Int32:
 		fallthrough
 	case reflect.Int16:
 		fallthrough
 	case reflect.Int8:
-		return pbInt32
+		return pbInt32, nil
 
 	case reflect.Uint:
 		fallthrough
 	case reflect.Uint64:
-		return pbUint64
+		return pbUint64, nil
 	case reflect.Uint32:
 		fallthrough
 	case reflect.Uint16:
 		fallthrough
 	case reflect.Uint8:
-		return pbUint32
+		return pbUint32, nil
 
 	case reflect.Bool:
-		return pbBool
+		return pbBool, nil
 
 	case reflect.String:
-		return pbString
+		return pbString, nil
 
-	case reflect.Slice:
-		fallthrough
 	case reflect.Array:
-		value := goType2PbType(t.Elem(), strictMode)
-		return pbArray + fieldSep + value
+		// 定长数组
+		if t.Elem().Kind() == reflect.Uint8 && c.arrayBehavior != ArrayBehaviorRepeated {
+			return pbBytes, nil
+		}
+		if c.arrayBehavior == ArrayBehaviorError {
+			return "", fmt.Errorf("unsupported fixed-size array type: %s", t.String())
+		}
+		fallthrough
+	case reflect.Slice:
+		// 动态值的数组
+		if t.Elem().Kind() == reflect.Interface && t.Elem().NumMethod() == 0 {
+			c.addImport(structImport)
+			return pbListValue, nil
+		}
+		value, err := c.goType2PbType(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		// repeated 字段不能再使用optional修饰
+		return pbArray + fieldSep + strings.TrimPrefix(value, pbOptional+fieldSep), nil
 
 	case reflect.Map:
+		// 值为struct{}的map表示集合，转换为repeated
+		if isSetType(t) {
+			key, err := c.goType2PbType(t.Key())
+			if err != nil {
+				return "", err
+			}
+			c.warn("set %s converted to %s", t.String(), pbArray+fieldSep+key)
+			return pbArray + fieldSep + key, nil
+		}
 		var value string
 		if !allowedMapKey(t.Key()) || !allowedMapValue(t.Elem()) {
 			// TODO: 支持复杂类型
-			if strictMode {
-				panic(fmt.Sprintf("unsupported map type: key:%s  value:%s\n", t.Key().String(), t.Elem().String()))
+			if c.strictMode {
+				return "", fmt.Errorf("unsupported map type: key:%s  value:%s", t.Key().String(), t.Elem().String())
 			} else {
+				c.warn("unsupported map type %s converted to %s", t.String(), pbAny)
+				c.addImport(anyImport)
 				value = pbAny
 			}
 		} else {
-			value = goType2PbType(t.Elem(), strictMode)
+			var err error
+			if value, err = c.goType2PbType(t.Elem()); err != nil {
+				return "", err
+			}
+			value = strings.TrimPrefix(value, pbOptional+fieldSep)
 		}
-		return pbMap + "<" + t.Key().String() + ", " + value + ">"
+		// map的键不能是枚举
+		key := integerScalar(t.Key().Kind())
+		if !isEnumType(t.Key()) {
+			var err error
+			if key, err = c.goType2PbType(t.Key()); err != nil {
+				return "", err
+			}
+		}
+		return pbMap + "<" + key + ", " + value + ">", nil
 
 	// case bytesType.Kind():
 	// 	return "bytes"
 
 	case reflect.Struct:
+		// well-known types
+		if wkt, ok := c.builtinType(fullName(t)); ok {
+			if len(wkt.importPath) > 0 {
+				c.addImport(wkt.importPath)
+			}
+			return wkt.pbType, nil
+		}
 		// 时间类型
 		if t.ConvertibleTo(timeType) {
-			return pbInt64
-		} else {
-			// 其他struct
-			return t.Name()
+			return pbInt64, nil
+		}
+		// 注册的可转换类型
+		for _, ct := range c.convertibleTypes {
+			if t.ConvertibleTo(ct.goType) {
+				return ct.protoType, nil
+			}
+		}
+		// 其他struct按名称引用而不展开字段，因此Tree{Left *Tree}等递归类型不会无限递归
+		// 其他分组的结构体使用完整名称并导入其文件
+		if pkg, ok := c.groupPackages[fullName(t)]; ok && pkg != c.packageName {
+			c.addImport(GroupFileName(pkg))
+			return pkg + "." + t.Name(), nil
 		}
+		if rt, ok := t.(reflectType); ok && c.importResolver != nil {
+			if path, ok := c.importResolver(rt.Type); ok {
+				c.addImport(path)
+			}
+		}
+		return t.Name(), nil
 	case reflect.Ptr:
-		return goType2PbType(t.Elem(), strictMode)
+		// 指向空接口的指针表示JSON null
+		if c.useWellKnownTypes && t.Elem().Kind() == reflect.Interface && t.Elem().NumMethod() == 0 {
+			c.addImport(structImport)
+			return pbNullValue, nil
+		}
+		if c.wrapperTypes && !isEnumType(t.Elem()) {
+			if wrapper, ok := wrapperTypes[t.Elem().Kind()]; ok {
+				c.addImport(wrappersImport)
+				return wrapper, nil
+			}
+		}
+		return c.goType2PbType(t.Elem())
+	case reflect.Interface:
+		// 集合元素等位置的接口无法确定具体类型，使用Any
+		if c.strictMode {
+			return "", fmt.Errorf("unsupported interface type: %s", t.String())
+		}
+		c.warn("interface type %s converted to %s", t.String(), pbAny)
+		c.addImport(anyImport)
+		return pbAny, nil
 	default:
-		panic(fmt.Sprintf("unsupported type: %s\n", k.String()))
+		return "", fmt.Errorf("unsupported type: %s", k.String())
 	}
 }
 
-func allowedMapValue(t reflect.Type) bool {
-	// map字段不能使用repeated关键字修饰
-	switch t.Kind() {
-	case reflect.Map:
-		return false
-	case reflect.Array:
+// isDurationType reports whether t is time.Duration.
+func isDurationType(t goType) bool {
+	return t.PkgPath() == "time" && t.Name() == "Duration"
+}
+
+// isEnumType reports whether t is a named integer type, such as an enum declared
+// with iota, which is converted to an enum. Pointers to them are not converted to
+// wrappers.
+func isEnumType(t goType) bool {
+	if len(t.PkgPath()) == 0 || isDurationType(t) {
 		return false
-	case reflect.Slice:
+	}
+	return isIntegerKind(t.Kind())
+}
+
+// isSetType reports whether t is a map used as a set, such as map[string]struct{},
+// which is converted to a repeated field of its keys.
+func isSetType(t goType) bool {
+	if t.Kind() != reflect.Map || !allowedMapKey(t.Key()) {
 		return false
-	default:
-		return true
 	}
+	elem := t.Elem()
+	return elem.Kind() == reflect.Struct && len(elem.Name()) == 0 && elem.NumField() == 0
 }
 
-func allowedMapKey(t reflect.Type) bool {
-	// 可以是任何证书或字符串类型（除浮点类型和字节之外的任何标量类型）、不能是枚举
-	switch t.Kind() {
-	case reflect.Map:
-		fallthrough
+// isSliceValueMap reports whether t is a map with slice values, such as
+// map[string][]string, which protobuf can only represent with a wrapper message.
+func isSliceValueMap(t goType) bool {
+	return t.Kind() == reflect.Map && allowedMapKey(t.Key()) && t.Elem().Kind() == reflect.Slice
+}
 
-	case reflect.Array:
-		fallthrough
-	case reflect.Slice:
-		fallthrough
+// sliceMap2Wrapper converts a map field with slice values to a wrapper message
+// `<FieldName>List { repeated <elem> values = 1; }` and the map type using it.
+// The tag of the values can be set with `pb:"map_value_tag=<N>"`.
+func (c *converter) sliceMap2Wrapper(sf goField) (Message, string, error) {
+	valueTag, err := tagDirective(c.directives(sf.Tag), "map_value_tag", 1)
+	if err != nil {
+		return Message{}, "", fmt.Errorf("%s: %w", sf.Name, err)
+	}
+	key, err := c.goType2PbType(sf.Type.Key())
+	if err != nil {
+		return Message{}, "", err
+	}
+	values, err := c.goType2PbType(sf.Type.Elem())
+	if err != nil {
+		return Message{}, "", err
+	}
+	wrapper := Message{
+		Name:   sf.Name + "List",
+		Fields: []MessageField{NewMessageField(values, "values", valueTag, "")},
+	}
+	return wrapper, pbMap + "<" + key + ", " + wrapper.Name + ">", nil
+}
 
-	case reflect.Float64:
-		fallthrough
-	case reflect.Float32:
-		return false
+// allowedMapValue reports whether t can be the value type of a proto map field.
+// Pointers are allowed to the allowed kinds, since they are converted to their
+// element type.
+func allowedMapValue(t goType) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return allowedMapValueKind(t.Kind())
+}
+
+func allowedMapValueKind(k reflect.Kind) bool {
+	// map字段的值可以是除map和repeated之外的标量或消息
+	switch k {
+	case reflect.Bool, reflect.String, reflect.Float64, reflect.Float32, reflect.Struct:
+		return true
 	default:
+		return isIntegerKind(k)
+	}
+}
+
+// allowedMapKey reports whether t can be the key type of a proto map field.
+func allowedMapKey(t goType) bool {
+	return allowedMapKeyKind(t.Kind())
+}
+
+func allowedMapKeyKind(k reflect.Kind) bool {
+	// 可以是任何整数或字符串类型（除浮点类型和字节之外的任何标量类型），不能是消息
+	return k == reflect.Bool || k == reflect.String || isIntegerKind(k)
+}
+
+// isIntegerKind reports whether k is an integer kind converted to a proto integer.
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+		reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
 		return true
+	default:
+		return false
 	}
 }
 
-// Camel2CamelLower big camel to small camel
+// AllowedMapKeyKinds returns the kinds of go map keys converted to proto map
+// fields, so that callers can check their structs before converting them. Maps
+// with other keys are an error in strict mode and converted to
+// google.protobuf.Any otherwise. The key type must still convert to a proto type.
+func AllowedMapKeyKinds() []reflect.Kind {
+	return allowedKinds(allowedMapKeyKind)
+}
+
+// AllowedMapValueKinds returns the kinds of go map values converted to proto map
+// fields, like AllowedMapKeyKinds. Pointers to these kinds are allowed too.
+func AllowedMapValueKinds() []reflect.Kind {
+	return allowedKinds(allowedMapValueKind)
+}
+
+// allowedKinds returns the valid kinds allowed by the allowed func, in order.
+func allowedKinds(allowed func(reflect.Kind) bool) []reflect.Kind {
+	var kinds []reflect.Kind
+	for k := reflect.Bool; k <= reflect.UnsafePointer; k++ {
+		if allowed(k) {
+			kinds = append(kinds, k)
+		}
+	}
+	return kinds
+}
+
+// defaultAcronyms are the acronyms lowered as a whole at the start of a name.
+var defaultAcronyms = []string{"ID", "URL", "HTTP", "API", "UUID", "SQL"}
+
+// Camel2CamelLower big camel to small camel. A leading acronym of defaultAcronyms
+// is lowered as a whole, e.g. IDToken to idToken.
 func Camel2CamelLower(s string) string {
-	a := strings.ToLower(string(s[0]))
-	return a + s[1:]
+	return camel2CamelLower(s, defaultAcronyms)
 }
 
-// get comment for the structure
-func getStructComment(vT reflect.Type) (string, map[string]string, error) {
-	structName := vT.PkgPath() + "." + vT.Name()
+// camelLower converts a go field name to a proto field name, with the acronyms
+// set by WithAcronyms.
+func (c *converter) camelLower(name string) string {
+	return camel2CamelLower(name, c.acronyms)
+}
 
-	var fieldCommentMap = make(map[string]string)
-	cmd := exec.Command("go", "doc", structName)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", nil, err
+// camel2CamelLower is Camel2CamelLower with the given acronyms. An acronym only
+// matches when it is not followed by a lowercase letter, except for a plural s,
+// so that Identity is still converted to identity by lowering its first letter.
+func camel2CamelLower(s string, acronyms []string) string {
+	if len(s) == 0 {
+		return s
 	}
-	buf := bytes.NewBuffer(output)
-	var (
-		isEnd   bool
-		comment string
-	)
-	for {
-		line, err := buf.ReadString('\n')
-		if err == io.EOF {
-			break
+	prefix := s[:1]
+	for _, acronym := range acronyms {
+		if len(acronym) <= len(prefix) || !strings.HasPrefix(s, acronym) {
+			continue
 		}
-		if err != nil {
-			return "", nil, err
+		// 复数形式，如URLs
+		if strings.HasPrefix(s[len(acronym):], "s") {
+			acronym += "s"
 		}
-
-		if strings.TrimSpace(line) == structEnd {
-			isEnd = true
-			continue
+		if rest := s[len(acronym):]; len(rest) == 0 || !unicode.IsLower(rune(rest[0])) {
+			prefix = acronym
 		}
+	}
+	return strings.ToLower(prefix) + s[len(prefix):]
+}
 
-		infoList := strings.Split(line, commentSep) // 拆分出注释行
-		if len(infoList) == 0 {
-			continue
+// sanitizeComment trims the comment prefixes and redundant spaces left in a
+// comment.
+func sanitizeComment(s string) string {
+	s = strings.TrimSpace(s)
+	for strings.HasPrefix(s, "//") {
+		s = strings.TrimSpace(strings.TrimLeft(s, "/"))
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// structDoc returns the documentation of the struct type t. For reflect types, the
+// sources of its package are parsed with go/doc on first use, and the documentation
+// of all its structs is kept for the other structs of the package. The
+// documentation of go/types types is added by Packages2ProtoFile when it loads
+// their package.
+func (c *converter) structDoc(ctx context.Context, t goType) (typeDoc, error) {
+	name := fullName(t)
+	if doc, ok := c.typeDocs[name]; ok {
+		return doc, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return typeDoc{}, err
+	}
+	if _, ok := t.(reflectType); ok {
+		if err := c.loadDocs(ctx, t.PkgPath()); err != nil {
+			return typeDoc{}, err
 		}
-		keyList := strings.Split(strings.TrimSpace(infoList[0]), fieldSep)
-		if !isEnd {
-			if len(keyList) == 1 { // 匿名结构体
-				continue
-			}
-			// 结构体定义头
-			var fieldName = keyList[0]
-			if fieldName == structStart {
-				continue
-			}
-			// 字段定义有注释
-			if len(keyList) >= 2 && len(infoList) >= 2 {
-				var commentList []string
-				for _, comment := range infoList[1:] {
-					commentList = append(commentList, strings.TrimSpace(comment))
-				}
-				fieldCommentMap[fieldName] = strings.Join(commentList, " ")
-			}
-		} else {
-			comment = strings.TrimSpace(line)
-			break
+	}
+	return c.typeDocs[name], nil
+}
+
+// loadDocs parses the sources of the package pkgPath on first use, and keeps the
+// documentation of its structs and the constants of its named types.
+func (c *converter) loadDocs(ctx context.Context, pkgPath string) error {
+	// 未命名的类型没有源码
+	if len(pkgPath) == 0 || c.docPackages[pkgPath] {
+		return nil
+	}
+	c.docPackages[pkgPath] = true
+	docs, consts, err := loadTypeDocs(ctx, pkgPath)
+	if err != nil {
+		return err
+	}
+	for n, doc := range docs {
+		if _, ok := c.typeDocs[n]; !ok {
+			c.typeDocs[n] = doc
 		}
 	}
-	return comment, fieldCommentMap, nil
+	for n, values := range consts {
+		c.enumConsts[n] = values
+	}
+	return nil
 }
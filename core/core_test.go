@@ -0,0 +1,394 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type Base struct {
+	ID   string
+	Kind int32
+}
+
+type ValueEmbedded struct {
+	Base
+	Name string
+}
+
+type PointerEmbedded struct {
+	*Base
+	Name string
+}
+
+type EmbeddedInt struct {
+	Status
+	Name string
+}
+
+type Status int32
+
+func TestStruct2PbFieldEmbedded(t *testing.T) {
+	for _, bean := range []interface{}{ValueEmbedded{}, PointerEmbedded{}} {
+		msg, err := Struct2PbWithContext(context.Background(), bean)
+		if err != nil {
+			t.Fatalf("%T: %v", bean, err)
+		}
+		var got []string
+		for _, f := range msg.Fields {
+			got = append(got, f.String())
+		}
+		want := []string{"string id = 1", "int32 kind = 2", "string name = 3"}
+		if strings.Join(got, "; ") != strings.Join(want, "; ") {
+			t.Errorf("%T: got fields %q, want %q", bean, got, want)
+		}
+	}
+}
+
+func TestStruct2PbFieldEmbeddedNonStruct(t *testing.T) {
+	if _, err := Struct2PbWithContext(context.Background(), EmbeddedInt{}); err == nil || !strings.Contains(err.Error(), "unsupported embedded type") {
+		t.Errorf("got error %v, want unsupported embedded type", err)
+	}
+}
+
+type EnumSlice struct {
+	Status   Status
+	Statuses []Status
+	Days     []time.Weekday
+	ByStatus map[Status]string
+}
+
+func TestGoType2PbTypeEnumSlice(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{EnumSlice{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "message EnumSlice {\n" +
+		"  Status status = 1;\n" +
+		"  repeated Status statuses = 2;\n" +
+		"  repeated Weekday days = 3;\n" +
+		"  map<int32, string> byStatus = 4;\n" +
+		"}\n"
+	if got := file.Messages[0].String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	// Status 没有常量，只有零值
+	if len(file.Enums) != 2 || file.Enums[0].String() != "enum Status {\n  STATUS_UNSPECIFIED = 0;\n}\n" {
+		t.Fatalf("enums = %v", file.Enums)
+	}
+	days := file.Enums[1]
+	if len(days.Values) != 7 || days.Values[0].String() != "WEEKDAY_SUNDAY = 0" || days.Values[6].String() != "WEEKDAY_SATURDAY = 6" {
+		t.Errorf("enum Weekday = %v", days)
+	}
+
+	desc := compileProto(t, file)
+	fields := desc.Messages().ByName("EnumSlice").Fields()
+	for _, name := range []protoreflect.Name{"status", "statuses"} {
+		field := fields.ByName(name)
+		if field.Kind() != protoreflect.EnumKind || field.Enum().Name() != "Status" {
+			t.Errorf("%s: kind = %v, want enum Status", name, field.Kind())
+		}
+	}
+	if !fields.ByName("statuses").IsList() || !fields.ByName("days").IsList() {
+		t.Error("statuses or days is not repeated")
+	}
+}
+
+type InterfaceFields struct {
+	Value   interface{}
+	Readers []io.Reader
+	Attrs   map[string]interface{}
+	Ptr     *io.Reader
+}
+
+func TestGoType2PbTypeInterface(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{InterfaceFields{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(file.String(), `import "google/protobuf/any.proto";`) {
+		t.Errorf("any.proto not imported:\n%s", file)
+	}
+	desc := compileProto(t, file)
+	fields := desc.Messages().ByName("InterfaceFields").Fields()
+	for _, name := range []protoreflect.Name{"value", "readers", "ptr"} {
+		if got := fields.ByName(name).Message().FullName(); got != "google.protobuf.Any" {
+			t.Errorf("%s: type = %s, want google.protobuf.Any", name, got)
+		}
+	}
+	if got := fields.ByName("attrs").MapValue().Message().FullName(); got != "google.protobuf.Any" {
+		t.Errorf("attrs: value type = %s, want google.protobuf.Any", got)
+	}
+
+	if _, err := Structs2ProtoFile([]interface{}{InterfaceFields{}}, WithStrictMode(true)); err == nil {
+		t.Error("strict mode accepted interface fields")
+	}
+}
+
+// mapKindTypes are types of each kind used to build map types in the tests.
+var mapKindTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+	reflect.Struct:  reflect.TypeOf(Base{}),
+}
+
+// mapFieldType converts a struct with a field of map type m in strict mode and
+// returns the proto type of the field.
+func mapFieldType(m reflect.Type) (string, error) {
+	t := reflect.StructOf([]reflect.StructField{{Name: "M", Type: m}})
+	message, err := Struct2PbRaw(t, WithStrictMode(true))
+	if err != nil {
+		return "", err
+	}
+	return message.Fields[0].Typ, nil
+}
+
+func TestAllowedMapKinds(t *testing.T) {
+	for _, k := range AllowedMapKeyKinds() {
+		key, ok := mapKindTypes[k]
+		if !ok {
+			t.Errorf("key kind %s: no test type", k)
+			continue
+		}
+		if typ, err := mapFieldType(reflect.MapOf(key, reflect.TypeOf(""))); err != nil || !strings.HasPrefix(typ, pbMap+"<") {
+			t.Errorf("key kind %s: type %q, err %v", k, typ, err)
+		}
+	}
+	for _, k := range AllowedMapValueKinds() {
+		value, ok := mapKindTypes[k]
+		if !ok {
+			t.Errorf("value kind %s: no test type", k)
+			continue
+		}
+		for _, v := range []reflect.Type{value, reflect.PtrTo(value)} {
+			if typ, err := mapFieldType(reflect.MapOf(reflect.TypeOf(""), v)); err != nil || !strings.HasPrefix(typ, pbMap+"<") {
+				t.Errorf("value type %s: type %q, err %v", v, typ, err)
+			}
+		}
+	}
+
+	rejected := []reflect.Type{
+		reflect.TypeOf(map[float64]string{}),
+		reflect.TypeOf(map[[4]byte]string{}),
+		reflect.TypeOf(map[Base]string{}),
+		reflect.TypeOf(map[*string]string{}),
+		reflect.TypeOf(map[string]chan int{}),
+		reflect.TypeOf(map[string]func(){}),
+		reflect.TypeOf(map[string]complex128{}),
+		reflect.TypeOf(map[string]map[string]string{}),
+	}
+	for _, m := range rejected {
+		if typ, err := mapFieldType(m); err == nil {
+			t.Errorf("%s converted to %q in strict mode", m, typ)
+		}
+	}
+}
+
+type SliceMaps struct {
+	Tags   map[string][]string
+	Scores map[int32][]float64 `pb:"map_value_tag=2"`
+}
+
+func TestSliceMap2Wrapper(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{SliceMaps{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "message SliceMaps {\n" +
+		"  map<string, TagsList> tags = 1;\n" +
+		"  map<int32, ScoresList> scores = 2;\n" +
+		"  message TagsList {\n" +
+		"    repeated string values = 1;\n" +
+		"  }\n" +
+		"  message ScoresList {\n" +
+		"    repeated double values = 2;\n" +
+		"  }\n" +
+		"}\n"
+	if got := file.Messages[0].String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	compileProto(t, file)
+}
+
+func TestReorderFields(t *testing.T) {
+	m := Message{
+		Name: "User",
+		Fields: []MessageField{
+			NewMessageField(pbString, "id", 1, ""),
+			NewMessageField(pbString, "name", 2, ""),
+			NewMessageField(pbInt32, "age", 3, ""),
+			NewMessageField(pbString, "email", 4, ""),
+		},
+		Oneofs: []Oneof{{Name: "contact", Fields: []MessageField{NewMessageField(pbString, "phone", 5, "")}}},
+	}
+	if err := m.ReorderFields([]string{"email", "id"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"string email = 1", "string id = 2", "string name = 3", "int32 age = 4"}
+	for i, f := range m.Fields {
+		if f.String() != want[i] {
+			t.Errorf("field %d = %s, want %s", i, f, want[i])
+		}
+	}
+	if got := m.Oneofs[0].Fields[0].Tag(); got != 5 {
+		t.Errorf("oneof field tag = %d, want 5", got)
+	}
+
+	if err := m.ReorderFields([]string{"missing"}); err == nil {
+		t.Error("unknown field accepted")
+	}
+	if err := m.ReorderFields([]string{"id", "id"}); err == nil {
+		t.Error("field listed twice accepted")
+	}
+}
+
+type DynamicList struct {
+	Tags []interface{}
+}
+
+func TestListValue(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{DynamicList{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := file.String()
+	for _, want := range []string{`import "google/protobuf/struct.proto";`, "google.protobuf.ListValue tags = 1;"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	field := compileProto(t, file).Messages().ByName("DynamicList").Fields().ByName("tags")
+	if field.IsList() || field.Message().FullName() != "google.protobuf.ListValue" {
+		t.Errorf("tags: list %v, type %s", field.IsList(), field.Message().FullName())
+	}
+}
+
+type SmallKeyMaps struct {
+	Int8Keys   map[int8]string
+	Int16Keys  map[int16]string
+	Uint8Keys  map[uint8]string
+	Uint16Keys map[uint16]string
+}
+
+func TestMapSmallIntegerKeys(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{SmallKeyMaps{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"map<int32, string> int8Keys = 1",
+		"map<int32, string> int16Keys = 2",
+		"map<uint32, string> uint8Keys = 3",
+		"map<uint32, string> uint16Keys = 4",
+	}
+	for i, f := range file.Messages[0].Fields {
+		if f.String() != want[i] {
+			t.Errorf("field %d = %s, want %s", i, f, want[i])
+		}
+	}
+	compileProto(t, file)
+}
+
+type (
+	UserID   string
+	UserName string
+	UserMap  map[UserID]UserName
+)
+
+type AliasMaps struct {
+	Names UserMap
+	IDs   map[UserName]UserID
+}
+
+func TestMapStringAliases(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{AliasMaps{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"map<string, string> names = 1",
+		"map<string, string> ids = 2",
+	}
+	for i, f := range file.Messages[0].Fields {
+		if f.String() != want[i] {
+			t.Errorf("field %d = %s, want %s", i, f, want[i])
+		}
+	}
+	compileProto(t, file)
+}
+
+type Tree struct {
+	Value       int32
+	Left, Right *Tree
+}
+
+type Node struct {
+	Name     string
+	Children []*Node
+	Parent   *Node
+}
+
+func TestRecursiveStructs(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{Tree{}, Node{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "message Tree {\n" +
+		"  int32 value = 1;\n" +
+		"  Tree left = 2;\n" +
+		"  Tree right = 3;\n" +
+		"}\n"
+	if got := file.Messages[0].String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	children := compileProto(t, file).Messages().ByName("Node").Fields().ByName("children")
+	if !children.IsList() || children.Message().Name() != "Node" {
+		t.Errorf("children: list %v, type %s", children.IsList(), children.Message().Name())
+	}
+}
+
+func TestNonStructBean(t *testing.T) {
+	var nilInt *int
+	for _, bean := range []interface{}{42, "x", new(int), []Base{}} {
+		if _, err := Structs2Pb(false, bean); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("Structs2Pb(%T): %v", bean, err)
+		}
+		if _, err := Structs2ProtoFile([]interface{}{bean}); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("Structs2ProtoFile(%T): %v", bean, err)
+		}
+		if _, err := Struct2PbWithContext(context.Background(), bean); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("Struct2PbWithContext(%T): %v", bean, err)
+		}
+		if _, err := Structs2PbGroups(map[string][]interface{}{"a": {bean}}); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("Structs2PbGroups(%T): %v", bean, err)
+		}
+		beans := make(chan interface{}, 1)
+		beans <- bean
+		close(beans)
+		msgs, errs := Structs2PbAsync(context.Background(), beans)
+		if _, err := CollectMessages(context.Background(), msgs, errs); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("Structs2PbAsync(%T): %v", bean, err)
+		}
+	}
+	if _, err := Structs2ProtoFile([]interface{}{nilInt}, WithNilStrategy(NilStrategyDereferenceType)); !errors.Is(err, ErrNotAStruct) {
+		t.Errorf("dereferenced nil *int: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+// Package coretest provides utilities for testing the proto files generated by
+// package core.
+package coretest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"struct2pb/core"
+)
+
+// update rewrites the golden files with the output instead of comparing them.
+var update = flag.Bool("update", false, "update the golden files")
+
+// GoldenTest converts structs to a proto file with opts and compares it with the
+// golden file testdata/<name>.golden, ignoring trailing newlines. A missing golden
+// file fails the test; run the test with -update to write the golden files.
+func GoldenTest(t testing.TB, name string, structs []interface{}, opts ...core.Option) {
+	t.Helper()
+	file, err := core.Structs2ProtoFile(structs, opts...)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	got := file.String()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("wrote %s", path)
+		return
+	}
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("%s does not exist, run with -update to write it", path)
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimRight(got, "\n") != strings.TrimRight(string(want), "\n") {
+		t.Errorf("%s does not match the output, run with -update to rewrite it\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
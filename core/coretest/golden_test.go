@@ -0,0 +1,66 @@
+package coretest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type A struct {
+	Name string
+}
+
+type B struct {
+	Count int32
+}
+
+// recorder records the failures of the tested helper instead of failing the test.
+type recorder struct {
+	testing.TB
+	failed bool
+	log    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+	r.log = fmt.Sprintf(format, args...)
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func (r *recorder) Fatal(args ...interface{}) {
+	r.failed = true
+	r.log = fmt.Sprint(args...)
+}
+
+func TestGoldenTest(t *testing.T) {
+	r := &recorder{TB: t}
+	GoldenTest(r, "missing", []interface{}{A{}})
+	if !r.failed {
+		t.Error("missing golden file did not fail")
+	}
+	if _, err := os.Stat(filepath.Join("testdata", "missing.golden")); !os.IsNotExist(err) {
+		t.Errorf("missing golden file was written: %v", err)
+	}
+
+	// a.golden ends with an extra newline
+	r = &recorder{TB: t}
+	GoldenTest(r, "a", []interface{}{A{}})
+	if r.failed {
+		t.Errorf("matching output failed: %s", r.log)
+	}
+	GoldenTest(r, "a", []interface{}{B{}})
+	if !r.failed {
+		t.Error("different output did not fail")
+	}
+	r = &recorder{TB: t}
+	GoldenTest(r, "a", []interface{}{42})
+	if !r.failed {
+		t.Error("conversion error did not fail")
+	}
+}
@@ -0,0 +1,251 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// descriptorTypes maps the proto scalar types to their descriptor field types.
+var descriptorTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double":   descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":    descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"int64":    descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint64":   descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"int32":    descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"fixed64":  descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	"fixed32":  descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	"bool":     descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string":   descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":    descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"uint32":   descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"sfixed32": descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+	"sint32":   descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":   descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+}
+
+// ToDescriptorProto builds the descriptor of the message. Map fields are repeated
+// fields of a nested map entry message, and optional fields get a synthetic oneof.
+// The type of message and enum fields is left for the descriptor builder to
+// resolve from the type name. Custom message and field options are not included.
+func (m Message) ToDescriptorProto() *descriptorpb.DescriptorProto {
+	d := &descriptorpb.DescriptorProto{Name: proto.String(m.Name)}
+	if deprecated, ok := m.Options["deprecated"]; ok {
+		d.Options = &descriptorpb.MessageOptions{Deprecated: proto.Bool(deprecated == "true")}
+	}
+	if m.IsMapEntry() {
+		if d.Options == nil {
+			d.Options = &descriptorpb.MessageOptions{}
+		}
+		d.Options.MapEntry = proto.Bool(true)
+	}
+	if m.MessageSetWireFormat {
+		if d.Options == nil {
+			d.Options = &descriptorpb.MessageOptions{}
+		}
+		d.Options.MessageSetWireFormat = proto.Bool(true)
+	}
+	for _, nested := range m.NestedMessages {
+		d.NestedType = append(d.NestedType, nested.ToDescriptorProto())
+	}
+	// 描述符的保留范围不包含结束值
+	for _, t := range m.ReservedTags {
+		d.ReservedRange = append(d.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+			Start: proto.Int32(int32(t)),
+			End:   proto.Int32(int32(t) + 1),
+		})
+	}
+	for _, r := range m.ReservedTagRanges {
+		d.ReservedRange = append(d.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+			Start: proto.Int32(int32(r[0])),
+			End:   proto.Int32(int32(r[1]) + 1),
+		})
+	}
+	d.ReservedName = m.ReservedNames
+
+	for _, o := range m.Oneofs {
+		d.OneofDecl = append(d.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String(o.Name)})
+	}
+	for _, f := range m.Fields {
+		if entry, ok := f.MapEntry(); ok {
+			d.NestedType = append(d.NestedType, entry.ToDescriptorProto())
+			f = f.WithType(pbArray + fieldSep + entry.Name)
+		}
+		fd := f.toDescriptorProto()
+		// proto3 的optional字段使用合成的oneof, 且排在其他oneof之后
+		if fd.GetProto3Optional() {
+			fd.OneofIndex = proto.Int32(int32(len(d.OneofDecl)))
+			d.OneofDecl = append(d.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.Name)})
+		}
+		d.Field = append(d.Field, fd)
+	}
+	for i, o := range m.Oneofs {
+		for _, f := range o.Fields {
+			fd := f.toDescriptorProto()
+			fd.OneofIndex = proto.Int32(int32(i))
+			d.Field = append(d.Field, fd)
+		}
+	}
+	return d
+}
+
+// toDescriptorProto builds the descriptor of a field of a proto3 message.
+func (f MessageField) toDescriptorProto() *descriptorpb.FieldDescriptorProto {
+	fd := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(f.Name),
+		Number:   proto.Int32(int32(f.Tag())),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String(jsonName(f.Name)),
+	}
+	typ := f.Typ
+	switch {
+	case strings.HasPrefix(typ, pbArray+fieldSep):
+		typ = strings.TrimPrefix(typ, pbArray+fieldSep)
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	case strings.HasPrefix(typ, pbOptional+fieldSep):
+		typ = strings.TrimPrefix(typ, pbOptional+fieldSep)
+		fd.Proto3Optional = proto.Bool(true)
+	}
+	if t, ok := descriptorTypes[typ]; ok {
+		fd.Type = t.Enum()
+	} else if typ == pbAny {
+		fd.TypeName = proto.String(".google.protobuf.Any")
+	} else {
+		fd.TypeName = proto.String(typ)
+	}
+
+	for _, opt := range f.options {
+		kv := strings.SplitN(opt.protoOption(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		name, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch name {
+		case "json_name":
+			if s, err := strconv.Unquote(value); err == nil {
+				fd.JsonName = proto.String(s)
+			}
+		case "packed":
+			if fd.Options == nil {
+				fd.Options = &descriptorpb.FieldOptions{}
+			}
+			fd.Options.Packed = proto.Bool(value == "true")
+		case "deprecated":
+			if fd.Options == nil {
+				fd.Options = &descriptorpb.FieldOptions{}
+			}
+			fd.Options.Deprecated = proto.Bool(value == "true")
+		}
+	}
+	return fd
+}
+
+// jsonName returns the default JSON name of a field, the way protoc derives it:
+// the field name in lowerCamelCase.
+func jsonName(fieldName string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range fieldName {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ToDescriptorProto builds the descriptor of the enum.
+func (e Enum) ToDescriptorProto() *descriptorpb.EnumDescriptorProto {
+	d := &descriptorpb.EnumDescriptorProto{Name: proto.String(e.Name)}
+	if e.AllowAlias {
+		d.Options = &descriptorpb.EnumOptions{AllowAlias: proto.Bool(true)}
+	}
+	for _, v := range e.Values {
+		d.Value = append(d.Value, &descriptorpb.EnumValueDescriptorProto{
+			Name:   proto.String(v.Name),
+			Number: proto.Int32(int32(v.Number)),
+		})
+	}
+	return d
+}
+
+// ToDescriptorProto builds the descriptor of the service. The options of its
+// RPCs are custom options, which are not included.
+func (s Service) ToDescriptorProto() *descriptorpb.ServiceDescriptorProto {
+	d := &descriptorpb.ServiceDescriptorProto{Name: proto.String(s.Name)}
+	for _, r := range s.RPCs {
+		md := &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(r.Name),
+			InputType:  proto.String(r.RequestType),
+			OutputType: proto.String(r.ResponseType),
+		}
+		if r.ClientStreaming {
+			md.ClientStreaming = proto.Bool(true)
+		}
+		if r.ServerStreaming {
+			md.ServerStreaming = proto.Bool(true)
+		}
+		d.Method = append(d.Method, md)
+	}
+	return d
+}
+
+// ToFileDescriptorProto builds the descriptor of the proto3 file, named name,
+// with the extension fields of its extend blocks. Custom file options are not
+// included.
+func (f ProtoFile) ToFileDescriptorProto(name string) (*descriptorpb.FileDescriptorProto, error) {
+	if f.Syntax != Proto3 {
+		return nil, fmt.Errorf("%s: descriptors can only be built for proto3 files", name)
+	}
+	d := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String(name),
+		Syntax:     proto.String("proto3"),
+		Dependency: append(append([]string(nil), f.Imports...), f.WeakImports...),
+	}
+	for i := range f.WeakImports {
+		d.WeakDependency = append(d.WeakDependency, int32(len(f.Imports)+i))
+	}
+	if len(f.Package) > 0 {
+		d.Package = proto.String(f.Package)
+	}
+	// 文件选项的值已按文本格式渲染
+	var options []string
+	for name, value := range f.Options {
+		if !strings.HasPrefix(name, "(") {
+			options = append(options, name+": "+value)
+		}
+	}
+	if len(options) > 0 {
+		d.Options = &descriptorpb.FileOptions{}
+		if err := prototext.Unmarshal([]byte(strings.Join(options, "\n")), d.Options); err != nil {
+			return nil, fmt.Errorf("%s: invalid file options: %w", name, err)
+		}
+	}
+	for _, m := range f.Messages {
+		d.MessageType = append(d.MessageType, m.ToDescriptorProto())
+	}
+	for _, e := range f.Enums {
+		d.EnumType = append(d.EnumType, e.ToDescriptorProto())
+	}
+	for _, s := range f.Services {
+		d.Service = append(d.Service, s.ToDescriptorProto())
+	}
+	for _, e := range f.Extends {
+		for _, field := range e.Fields {
+			fd := field.toDescriptorProto()
+			fd.Extendee = proto.String(e.Extendee)
+			d.Extension = append(d.Extension, fd)
+		}
+	}
+	return d, nil
+}
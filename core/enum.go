@@ -0,0 +1,221 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/types"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// EnumValue represents a value of a protocol buffer enum.
+type EnumValue struct {
+	Name    string
+	Number  int
+	Comment string
+}
+
+// String returns a string representation of an enum value.
+func (v EnumValue) String() string {
+	return fmt.Sprintf("%s = %d", v.Name, v.Number)
+}
+
+// Enum represents a protocol buffer enum.
+type Enum struct {
+	Name    string
+	Comment string
+	// AllowAlias allows values to share a number, by setting the allow_alias option.
+	AllowAlias bool
+	Values     []EnumValue
+}
+
+// AddValue appends a value to the enum. It returns an error if the value would be
+// the first one and is not zero, or if its name or number (unless AllowAlias is
+// set) is already used.
+func (e *Enum) AddValue(v EnumValue) error {
+	if len(e.Values) == 0 && v.Number != 0 {
+		return ErrFirstEnumValueMustBeZero
+	}
+	for _, ev := range e.Values {
+		if ev.Number == v.Number && !e.AllowAlias {
+			return fmt.Errorf("enum %s: duplicate value number %d (%s and %s)", e.Name, v.Number, ev.Name, v.Name)
+		}
+		if ev.Name == v.Name {
+			return fmt.Errorf("enum %s: duplicate value name %s", e.Name, v.Name)
+		}
+	}
+	e.Values = append(e.Values, v)
+	return nil
+}
+
+// FirstValueIsZero reports whether the enum starts with a zero value.
+func (e Enum) FirstValueIsZero() bool {
+	return len(e.Values) > 0 && e.Values[0].Number == 0
+}
+
+// HasAliases reports whether values of the enum share a number.
+func (e Enum) HasAliases() bool {
+	numbers := make(map[int]bool, len(e.Values))
+	for _, v := range e.Values {
+		if numbers[v.Number] {
+			return true
+		}
+		numbers[v.Number] = true
+	}
+	return false
+}
+
+// Validate checks that the enum can be compiled as proto3. Values may only share
+// a number if AllowAlias is set; ProtoLinter warns when it is set needlessly.
+func (e Enum) Validate() error {
+	return e.ValidateSyntax(Proto3)
+}
+
+// ValidateSyntax checks that the enum can be compiled with the syntax or edition.
+// Proto2 enums may start with any value and have negative values, while the
+// others must start with zero and have no negative values.
+func (e Enum) ValidateSyntax(v SyntaxVersion) error {
+	if v != Proto2 {
+		if !e.FirstValueIsZero() {
+			return fmt.Errorf("enum %s: %w", e.Name, ErrFirstEnumValueMustBeZero)
+		}
+		for _, value := range e.Values {
+			if value.Number < 0 {
+				return fmt.Errorf("enum %s: negative value %s = %d", e.Name, value.Name, value.Number)
+			}
+		}
+	}
+	numbers := make(map[int]string, len(e.Values))
+	names := make(map[string]bool, len(e.Values))
+	for _, v := range e.Values {
+		if name, ok := numbers[v.Number]; ok && !e.AllowAlias {
+			return fmt.Errorf("enum %s: duplicate value number %d (%s and %s)", e.Name, v.Number, name, v.Name)
+		}
+		if names[v.Name] {
+			return fmt.Errorf("enum %s: duplicate value name %s", e.Name, v.Name)
+		}
+		numbers[v.Number] = v.Name
+		names[v.Name] = true
+	}
+	return nil
+}
+
+// String returns a string representation of an Enum.
+func (e Enum) String() string {
+	return e.stringWithStyle(defaultRenderStyle)
+}
+
+// stringWithStyle renders the enum with its values indented by style.indent.
+func (e Enum) stringWithStyle(style renderStyle) string {
+	var buf bytes.Buffer
+
+	if len(e.Comment) > 0 {
+		buf.WriteString(fmt.Sprintf("// %s\n", e.Comment))
+	}
+	buf.WriteString(fmt.Sprintf("enum %s {\n", e.Name))
+	if e.AllowAlias {
+		buf.WriteString(fmt.Sprintf("%soption allow_alias = true;\n", style.indent))
+	}
+	for _, v := range e.Values {
+		if len(v.Comment) > 0 {
+			buf.WriteString(fmt.Sprintf("%s%s; // %s\n", style.indent, v, v.Comment))
+		} else {
+			buf.WriteString(fmt.Sprintf("%s%s;\n", style.indent, v))
+		}
+	}
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+// addEnumType records the named integer type t to declare as an enum.
+func (c *converter) addEnumType(t goType) {
+	for _, e := range c.enumTypes {
+		if fullName(e) == fullName(t) {
+			return
+		}
+	}
+	c.enumTypes = append(c.enumTypes, t)
+}
+
+// enumDecl declares the named integer type t as an enum with a value for each of
+// its constants, named in UPPER_SNAKE_CASE with the name of the enum as prefix,
+// e.g. STATUS_ACTIVE for StatusActive or Active of type Status. Unless the syntax
+// is proto2, a STATUS_UNSPECIFIED = 0 value is added if no constant is zero, and
+// negative constants are skipped; constants out of the int32 range are skipped.
+// Constants sharing a value are aliases.
+func (c *converter) enumDecl(ctx context.Context, t goType) (Enum, error) {
+	var consts []enumConst
+	if tt, ok := t.(typesType); ok {
+		named := tt.Type.(*types.Named)
+		consts = scopeEnumConsts(named.Obj().Pkg().Scope())[fullName(t)]
+	} else {
+		if err := c.loadDocs(ctx, t.PkgPath()); err != nil {
+			return Enum{}, err
+		}
+		consts = c.enumConsts[fullName(t)]
+	}
+	sort.SliceStable(consts, func(i, j int) bool {
+		return consts[i].value < consts[j].value
+	})
+
+	prefix := upperSnakeCase(t.Name()) + "_"
+	e := Enum{Name: t.Name()}
+	numbers := make(map[int64]bool, len(consts))
+	for _, ct := range consts {
+		if ct.value < math.MinInt32 || ct.value > math.MaxInt32 || (ct.value < 0 && c.syntax != Proto2) {
+			if c.strictMode {
+				return Enum{}, fmt.Errorf("enum %s: invalid value %s = %d", e.Name, ct.name, ct.value)
+			}
+			continue
+		}
+		name := upperSnakeCase(ct.name)
+		if !strings.HasPrefix(name, prefix) {
+			name = prefix + name
+		}
+		e.AllowAlias = e.AllowAlias || numbers[ct.value]
+		numbers[ct.value] = true
+		e.Values = append(e.Values, EnumValue{Name: name, Number: int(ct.value)})
+	}
+	// proto3 的枚举必须以0开始
+	if len(e.Values) == 0 || (!numbers[0] && c.syntax != Proto2) {
+		e.Values = append([]EnumValue{{Name: prefix + "UNSPECIFIED", Number: 0}}, e.Values...)
+	}
+	return e, nil
+}
+
+// upperSnakeCase converts a go name to UPPER_SNAKE_CASE, keeping acronyms
+// together, e.g. StatusHTTPError to STATUS_HTTP_ERROR.
+func upperSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// integerScalar returns the proto scalar of an integer kind.
+func integerScalar(k reflect.Kind) string {
+	switch k {
+	case reflect.Int, reflect.Int64:
+		return pbInt64
+	case reflect.Uint, reflect.Uint64:
+		return pbUint64
+	case reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		return pbUint32
+	default:
+		return pbInt32
+	}
+}
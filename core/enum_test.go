@@ -0,0 +1,31 @@
+package core
+
+import "testing"
+
+func TestEnumValidateNegativeValues(t *testing.T) {
+	e := Enum{Name: "Level", Values: []EnumValue{
+		{Name: "LEVEL_UNSPECIFIED", Number: 0},
+		{Name: "LEVEL_LOW", Number: -1},
+	}}
+	for _, tt := range []struct {
+		syntax SyntaxVersion
+		valid  bool
+	}{
+		{Proto2, true},
+		{Proto3, false},
+		{Edition2023, false},
+	} {
+		if err := e.ValidateSyntax(tt.syntax); (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v, want valid %v", tt.syntax, err, tt.valid)
+		}
+	}
+	if err := e.Validate(); err == nil {
+		t.Error("Validate accepted a negative value, want proto3 rules")
+	}
+
+	// proto2 的枚举可以从负数开始
+	e.Values[0], e.Values[1] = e.Values[1], e.Values[0]
+	if err := e.ValidateSyntax(Proto2); err != nil {
+		t.Errorf("proto2 enum starting with a negative value: %v", err)
+	}
+}
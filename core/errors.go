@@ -0,0 +1,47 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrFirstEnumValueMustBeZero is returned when the first value of an enum is not zero,
+	// which proto3 requires as the default value.
+	ErrFirstEnumValueMustBeZero = errors.New("the first enum value must be zero")
+
+	// ErrNotAStruct is returned when a type to convert is not a struct.
+	ErrNotAStruct = errors.New("not a struct")
+
+	// ErrNilBean is returned when a bean to convert is nil, unless set otherwise
+	// by WithNilStrategy.
+	ErrNilBean = errors.New("nil bean")
+
+	// ErrReflectOnlyOption is returned when converting go/types types with an option
+	// taking reflect types, which they do not have.
+	ErrReflectOnlyOption = errors.New("option only supported for reflect types")
+)
+
+// ValidationError reports an RPC argument type that is not declared in the file.
+type ValidationError struct {
+	Service string
+	RPC     string
+	Type    string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("service %s: rpc %s: undeclared type %s", e.Service, e.RPC, e.Type)
+}
+
+// InvalidTagError reports a field number out of [1, 536870911], or in the range
+// [19000, 19999] reserved for the protobuf implementation.
+type InvalidTagError struct {
+	Tag int
+}
+
+func (e InvalidTagError) Error() string {
+	if e.Tag >= firstReservedTag && e.Tag <= lastReservedTag {
+		return fmt.Sprintf("field number %d is reserved for the protobuf implementation", e.Tag)
+	}
+	return fmt.Sprintf("field number %d is not in [1, %d]", e.Tag, maxFieldTag)
+}
@@ -0,0 +1,37 @@
+package core
+
+import "strconv"
+
+// FieldOption is an option of a message field, rendered in brackets after the tag,
+// e.g. `string name = 1 [json_name = "n"];`.
+type FieldOption interface {
+	protoOption() string
+}
+
+// fieldOption is a field option rendered verbatim.
+type fieldOption string
+
+func (o fieldOption) protoOption() string {
+	return string(o)
+}
+
+// JSONNameOption sets the JSON name of the field.
+func JSONNameOption(name string) FieldOption {
+	return fieldOption("json_name = " + strconv.Quote(name))
+}
+
+// PackedOption uses the packed encoding for a repeated scalar field.
+func PackedOption() FieldOption {
+	return fieldOption("packed = true")
+}
+
+// DeprecatedOption marks the field as deprecated.
+func DeprecatedOption() FieldOption {
+	return fieldOption("deprecated = true")
+}
+
+// ValidateOption adds a protoc-gen-validate rule, e.g. ValidateOption("string.min_len = 1")
+// is rendered as `(validate.rules).string.min_len = 1`.
+func ValidateOption(rule string) FieldOption {
+	return fieldOption("(validate.rules)." + rule)
+}
@@ -0,0 +1,294 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Extend represents an extend block declaring extension fields of a message.
+type Extend struct {
+	Extendee string
+	Fields   []MessageField
+}
+
+// String returns a string representation of an Extend.
+func (e Extend) String() string {
+	return e.stringWithStyle(defaultRenderStyle)
+}
+
+// stringWithStyle renders the extend block with its fields indented by style.indent.
+func (e Extend) stringWithStyle(style renderStyle) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("extend %s {\n", e.Extendee))
+	for _, f := range e.Fields {
+		buf.WriteString(fmt.Sprintf("%s%s;\n", style.indent, f))
+	}
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+// CommentStyle controls where the comments of fields are rendered.
+type CommentStyle int
+
+const (
+	// CommentStyleInline renders comments after the field: `string name = 1; // comment`.
+	CommentStyleInline CommentStyle = iota
+	// CommentStyleLeading renders comments on the line before the field.
+	CommentStyleLeading
+)
+
+// renderStyle holds the settings of the text rendering of messages.
+type renderStyle struct {
+	indent       string
+	commentStyle CommentStyle
+	// bareReservedNames renders reserved names as identifiers, as editions require,
+	// instead of strings.
+	bareReservedNames bool
+}
+
+// defaultRenderStyle is the style of Message.String.
+var defaultRenderStyle = renderStyle{indent: indent}
+
+// ProtoFile represents a protocol buffer file.
+type ProtoFile struct {
+	// Header is written before the syntax statement, each line as a `//` comment.
+	Header   string
+	Syntax   SyntaxVersion
+	Package  string
+	Imports  []string
+	Options  map[string]string
+	Extends  []Extend
+	Messages []Message
+	Enums    []Enum
+	Services []Service
+	// WeakImports are imported with `import weak` after Imports. Their absence
+	// does not fail the compilation.
+	WeakImports []string
+	// CommentStyle places the comments of fields inline or on their own line.
+	CommentStyle CommentStyle
+	// Indent is the whitespace indenting declarations in blocks, two spaces if empty.
+	Indent string
+
+	// importResolver resolves the imports of types without a built-in import.
+	importResolver func(reflect.Type) (string, bool)
+}
+
+// typeImports maps the full name of go types to the proto files declaring the
+// proto types they correspond to, in addition to wellKnownTypes.
+var typeImports = map[string]string{
+	"time.Time":     "google/protobuf/timestamp.proto",
+	"time.Duration": durationImport,
+}
+
+// GoPackagePath2ProtoPackage converts a go package path to a proto package name,
+// dropping the domain of the path, e.g. github.com/example/service/user to
+// example.service.user.
+func GoPackagePath2ProtoPackage(goPath string) string {
+	elems := strings.Split(goPath, "/")
+	if len(elems) > 1 && strings.Contains(elems[0], ".") {
+		elems = elems[1:]
+	}
+	var names []string
+	for _, elem := range elems {
+		name := strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+				return r
+			}
+			if r >= 'A' && r <= 'Z' {
+				return unicode.ToLower(r)
+			}
+			return '_'
+		}, elem)
+		if len(name) == 0 {
+			continue
+		}
+		// 包名的每一段都必须是标识符
+		if name[0] >= '0' && name[0] <= '9' {
+			name = "_" + name
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ".")
+}
+
+// AddImportForType adds the import of the proto file declaring the proto type of
+// goType. Built-in mappings of well-known go types are checked first, then the
+// resolver set by WithImportResolver. It reports whether an import was found.
+func (f *ProtoFile) AddImportForType(goType reflect.Type) bool {
+	for goType.Kind() == reflect.Ptr || goType.Kind() == reflect.Slice || goType.Kind() == reflect.Array {
+		goType = goType.Elem()
+	}
+	name := goType.PkgPath() + "." + goType.Name()
+	path, ok := typeImports[name]
+	if wkt, isWkt := wellKnownTypes[name]; isWkt && len(wkt.importPath) > 0 {
+		path, ok = wkt.importPath, true
+	}
+	if !ok && f.importResolver != nil {
+		path, ok = f.importResolver(goType)
+	}
+	if !ok {
+		return false
+	}
+	f.addImport(path)
+	return true
+}
+
+// addImport adds an import to the file, keeping the imports sorted.
+func (f *ProtoFile) addImport(path string) {
+	for _, imp := range f.Imports {
+		if imp == path {
+			return
+		}
+	}
+	f.Imports = append(f.Imports, path)
+	sort.Strings(f.Imports)
+}
+
+// addWeakImport adds a weak import to the file, keeping the weak imports sorted.
+func (f *ProtoFile) addWeakImport(path string) {
+	for _, imp := range f.WeakImports {
+		if imp == path {
+			return
+		}
+	}
+	f.WeakImports = append(f.WeakImports, path)
+	sort.Strings(f.WeakImports)
+}
+
+// AddService appends a service to the file, importing HTTPRuleImport if one of its
+// RPCs has an HTTP rule.
+func (f *ProtoFile) AddService(s Service) {
+	f.Services = append(f.Services, s)
+	for _, r := range s.RPCs {
+		if _, ok := r.Options["(google.api.http)"]; ok {
+			f.addImport(HTTPRuleImport)
+		}
+	}
+}
+
+// LookupMessage returns the top-level message with the given name. The message
+// points into the file, so changes to it are reflected in the output.
+func (f *ProtoFile) LookupMessage(name string) (*Message, bool) {
+	for i := range f.Messages {
+		if f.Messages[i].Name == name {
+			return &f.Messages[i], true
+		}
+	}
+	return nil, false
+}
+
+// LookupEnum returns the top-level enum with the given name, pointing into the file.
+func (f *ProtoFile) LookupEnum(name string) (*Enum, bool) {
+	for i := range f.Enums {
+		if f.Enums[i].Name == name {
+			return &f.Enums[i], true
+		}
+	}
+	return nil, false
+}
+
+// LookupService returns the service with the given name, pointing into the file.
+func (f *ProtoFile) LookupService(name string) (*Service, bool) {
+	for i := range f.Services {
+		if f.Services[i].Name == name {
+			return &f.Services[i], true
+		}
+	}
+	return nil, false
+}
+
+// Validate checks that the messages, enums and services of the file can be
+// compiled.
+func (f ProtoFile) Validate() error {
+	for _, m := range f.Messages {
+		if err := m.ValidateSyntax(f.Syntax); err != nil {
+			return err
+		}
+	}
+	for _, e := range f.Enums {
+		if err := e.ValidateSyntax(f.Syntax); err != nil {
+			return err
+		}
+	}
+	for _, s := range f.Services {
+		if errs := s.Validate(&f); len(errs) > 0 {
+			return errs[0]
+		}
+	}
+	return nil
+}
+
+// WriteTo validates the file and writes its string representation to w.
+func (f ProtoFile) WriteTo(w io.Writer) (int64, error) {
+	if err := f.Validate(); err != nil {
+		return 0, err
+	}
+	n, err := io.WriteString(w, f.String())
+	return int64(n), err
+}
+
+// String returns a string representation of a ProtoFile.
+func (f ProtoFile) String() string {
+	var buf bytes.Buffer
+
+	if len(f.Header) > 0 {
+		for _, line := range strings.Split(f.Header, "\n") {
+			buf.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString(f.Syntax.String() + "\n\n")
+	if len(f.Package) > 0 {
+		buf.WriteString(fmt.Sprintf("package %s;\n\n", f.Package))
+	}
+	for _, path := range f.Imports {
+		buf.WriteString(fmt.Sprintf("import \"%s\";\n", path))
+	}
+	for _, path := range f.WeakImports {
+		buf.WriteString(fmt.Sprintf("import weak \"%s\";\n", path))
+	}
+	if len(f.Imports) > 0 || len(f.WeakImports) > 0 {
+		buf.WriteString("\n")
+	}
+	optionNames := make([]string, 0, len(f.Options))
+	for name := range f.Options {
+		optionNames = append(optionNames, name)
+	}
+	sort.Strings(optionNames)
+	for _, name := range optionNames {
+		buf.WriteString(fmt.Sprintf("option %s = %s;\n", name, f.Options[name]))
+	}
+	if len(f.Options) > 0 {
+		buf.WriteString("\n")
+	}
+	// 各定义以一个空行分隔，文件以单个换行结尾
+	style := defaultRenderStyle
+	style.commentStyle = f.CommentStyle
+	style.bareReservedNames = f.Syntax == Edition2023
+	if len(f.Indent) > 0 {
+		style.indent = f.Indent
+	}
+	var blocks []string
+	for _, e := range f.Extends {
+		blocks = append(blocks, f.Syntax.translateExtend(e).stringWithStyle(style))
+	}
+	for _, m := range f.Messages {
+		blocks = append(blocks, f.Syntax.translateMessage(m).stringAtDepth(0, style))
+	}
+	for _, e := range f.Enums {
+		blocks = append(blocks, e.stringWithStyle(style))
+	}
+	for _, s := range f.Services {
+		blocks = append(blocks, s.stringWithStyle(style))
+	}
+	buf.WriteString(strings.Join(blocks, "\n"))
+
+	return buf.String()
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type FirstMessage struct {
+	Name string
+}
+
+type SecondMessage struct {
+	Count int32
+}
+
+func TestProtoFileStringTwoMessages(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{FirstMessage{}, SecondMessage{}}, WithPackageName("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "syntax = \"proto3\";\n" +
+		"\n" +
+		"package acme;\n" +
+		"\n" +
+		"message FirstMessage {\n" +
+		"  string name = 1;\n" +
+		"}\n" +
+		"\n" +
+		"message SecondMessage {\n" +
+		"  int32 count = 1;\n" +
+		"}\n"
+	if got := file.String(); got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+	if got := file.Messages[0].String(); !strings.HasSuffix(got, "}\n") || strings.HasSuffix(got, "\n\n") {
+		t.Errorf("message does not end with exactly one newline: %q", got)
+	}
+}
+
+func TestJavaFileOptions(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{FirstMessage{}}, WithPackageName("acme"),
+		WithJavaPackage("com.acme.proto"), WithJavaOuterClassname("AcmeProto"), WithJavaMultipleFiles(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package acme;\n" +
+		"\n" +
+		"option java_multiple_files = true;\n" +
+		"option java_outer_classname = \"AcmeProto\";\n" +
+		"option java_package = \"com.acme.proto\";\n" +
+		"\n" +
+		"message FirstMessage {"
+	if got := file.String(); !strings.Contains(got, want) {
+		t.Errorf("got:\n%s\nwant it to contain:\n%s", got, want)
+	}
+	opts := compileProto(t, file).Options().(*descriptorpb.FileOptions)
+	if !opts.GetJavaMultipleFiles() || opts.GetJavaOuterClassname() != "AcmeProto" || opts.GetJavaPackage() != "com.acme.proto" {
+		t.Errorf("descriptor options = %v", opts)
+	}
+}
+
+func TestProtoSyntaxComment(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{FirstMessage{}}, WithPackageName("acme"), WithProtoSyntaxComment(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "// Generated by struct2pb " + Version() + "\n" +
+		"\n" +
+		"syntax = \"proto3\";\n" +
+		"\n" +
+		"package acme;\n"
+	if got := file.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("got:\n%s\nwant it to start with:\n%s", got, want)
+	}
+
+	file, err = Structs2ProtoFile([]interface{}{FirstMessage{}}, WithProtoSyntaxComment(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := file.String(); !strings.HasPrefix(got, "syntax = ") {
+		t.Errorf("got header without WithProtoSyntaxComment:\n%s", got)
+	}
+}
+
+func TestEditionReservedNames(t *testing.T) {
+	for _, tt := range []struct {
+		syntax SyntaxVersion
+		want   string
+	}{
+		{Proto3, "  reserved \"old_name\", \"legacy\";\n"},
+		{Proto2, "  reserved \"old_name\", \"legacy\";\n"},
+		{Edition2023, "  reserved old_name, legacy;\n"},
+	} {
+		file, err := Structs2ProtoFile([]interface{}{FirstMessage{}}, WithSyntax(tt.syntax))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Messages[0].ReservedNames = []string{"old_name", "legacy"}
+		got := file.String()
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got:\n%s\nwant it to contain:\n%s", tt.syntax, got, tt.want)
+		}
+		text := got[strings.Index(got, "message FirstMessage"):]
+		parsed, err := ParseProtoMessage(text)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.syntax, err)
+		}
+		if names := strings.Join(parsed.ReservedNames, ","); names != "old_name,legacy" {
+			t.Errorf("%s: parsed reserved names %s", tt.syntax, names)
+		}
+	}
+}
+
+func TestEditionGoFeaturesImport(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{FirstMessage{}}, WithSyntax(Edition2023))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Imports) > 0 {
+		t.Errorf("imports without go features = %v", file.Imports)
+	}
+
+	file, err = Structs2ProtoFile([]interface{}{FirstMessage{}}, WithSyntax(Edition2023),
+		withFileOption("features.(pb.go).legacy_unmarshal_json_enum", "true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Imports) != 1 || file.Imports[0] != goFeaturesImport {
+		t.Errorf("imports with go features = %v", file.Imports)
+	}
+}
@@ -0,0 +1,13 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GenerateGoGenerateComment returns a go:generate directive running struct2pb on
+// the given types of pkg, to paste above the Go source file declaring them, e.g.
+// `//go:generate struct2pb -pkg=struct2pb/obj -out=obj.proto -types=User,Job`.
+func GenerateGoGenerateComment(pkg, outputFile string, types []string) string {
+	return fmt.Sprintf("//go:generate struct2pb -pkg=%s -out=%s -types=%s", pkg, outputFile, strings.Join(types, ","))
+}
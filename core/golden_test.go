@@ -0,0 +1,14 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"struct2pb/core"
+	"struct2pb/core/coretest"
+	"struct2pb/obj"
+)
+
+func TestStructs2ProtoFileGolden(t *testing.T) {
+	coretest.GoldenTest(t, "obj", obj.List, core.WithConvertibleType(time.Time{}, "int64"))
+}
@@ -0,0 +1,66 @@
+package core
+
+import "reflect"
+
+// goType is a go type to convert. It is implemented by the reflect types of the
+// beans and by the go/types types of the packages loaded from source, so that both
+// are converted by the same code. The methods behave like those of reflect.Type.
+type goType interface {
+	// Kind returns the kind of the underlying type, reflect.Invalid if there is no
+	// corresponding kind.
+	Kind() reflect.Kind
+	Name() string
+	PkgPath() string
+	String() string
+	// Elem returns the element type of an array, map, pointer or slice type.
+	Elem() goType
+	// Key returns the key type of a map type.
+	Key() goType
+	// NumMethod returns the number of methods of an interface type.
+	NumMethod() int
+	NumField() int
+	Field(i int) goField
+	// ConvertibleTo reports whether the values of the type can be converted to u.
+	ConvertibleTo(u reflect.Type) bool
+}
+
+// goField is a field of a struct type.
+type goField struct {
+	Name      string
+	Type      goType
+	Tag       string
+	Exported  bool
+	Anonymous bool
+}
+
+// fullName returns the package path and name of the type, e.g. time.Time.
+func fullName(t goType) string {
+	return t.PkgPath() + "." + t.Name()
+}
+
+// reflectType is the goType of a reflect type.
+type reflectType struct {
+	reflect.Type
+}
+
+// Elem implements goType.
+func (t reflectType) Elem() goType {
+	return reflectType{t.Type.Elem()}
+}
+
+// Key implements goType.
+func (t reflectType) Key() goType {
+	return reflectType{t.Type.Key()}
+}
+
+// Field implements goType.
+func (t reflectType) Field(i int) goField {
+	sf := t.Type.Field(i)
+	return goField{
+		Name:      sf.Name,
+		Type:      reflectType{sf.Type},
+		Tag:       string(sf.Tag),
+		Exported:  len(sf.PkgPath) == 0,
+		Anonymous: sf.Anonymous,
+	}
+}
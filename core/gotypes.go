@@ -0,0 +1,324 @@
+package core
+
+import (
+	"context"
+	"go/ast"
+	"go/constant"
+	"go/doc"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+// typeDoc holds the documentation of a struct parsed from its source.
+type typeDoc struct {
+	comment       string
+	fieldComments map[string]string
+	optionLines   []string
+}
+
+// astTypeDocs extracts the documentation of the structs declared in files with
+// go/doc, keyed by their full name. Only the first paragraph of a struct comment
+// and the line comments of fields are used.
+func astTypeDocs(fset *token.FileSet, files []*ast.File, pkgPath string) (map[string]typeDoc, error) {
+	pkg, err := doc.NewFromFiles(fset, files, pkgPath, doc.AllDecls)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]typeDoc, len(pkg.Types))
+	for _, t := range pkg.Types {
+		typeSpec, ok := t.Decl.Specs[0].(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+		structType, ok := typeSpec.Type.(*ast.StructType)
+		if !ok {
+			continue
+		}
+		d := typeDoc{fieldComments: make(map[string]string)}
+		d.comment, d.optionLines = splitDocText(t.Doc)
+		for _, field := range structType.Fields.List {
+			if field.Comment == nil {
+				continue
+			}
+			comment := strings.Join(strings.Fields(field.Comment.Text()), " ")
+			for _, name := range field.Names {
+				d.fieldComments[name.Name] = comment
+			}
+		}
+		docs[pkgPath+"."+t.Name] = d
+	}
+	return docs, nil
+}
+
+// loadTypeDocs parses the sources of the package with the given import path and
+// extracts the documentation of its structs and the constants of its named types.
+func loadTypeDocs(ctx context.Context, pkgPath string) (map[string]typeDoc, map[string][]enumConst, error) {
+	fset := token.NewFileSet()
+	files, err := parsePackage(ctx, fset, pkgPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	docs, err := astTypeDocs(fset, files, pkgPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return docs, astEnumConsts(fset, files, pkgPath), nil
+}
+
+// enumConst is an integer constant of a named type, converted to an enum value.
+type enumConst struct {
+	name  string
+	value int64
+}
+
+// astEnumConsts type-checks the files of the package for the integer constants of
+// its named types, keyed by the full name of their type. The imports of the
+// package are not loaded: the constants of its own types do not depend on them.
+func astEnumConsts(fset *token.FileSet, files []*ast.File, pkgPath string) map[string][]enumConst {
+	// 忽略未解析的导入等错误
+	conf := types.Config{Error: func(error) {}}
+	pkg, _ := conf.Check(pkgPath, fset, files, nil)
+	return scopeEnumConsts(pkg.Scope())
+}
+
+// scopeEnumConsts returns the integer constants of named types declared in scope,
+// keyed by the full name of their type.
+func scopeEnumConsts(scope *types.Scope) map[string][]enumConst {
+	consts := make(map[string][]enumConst)
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if !ok {
+			continue
+		}
+		named, ok := c.Type().(*types.Named)
+		if !ok || named.Obj().Pkg() == nil || c.Val().Kind() != constant.Int {
+			continue
+		}
+		value, exact := constant.Int64Val(c.Val())
+		if !exact {
+			continue
+		}
+		t := fullName(typesType{named})
+		consts[t] = append(consts[t], enumConst{name: name, value: value})
+	}
+	return consts
+}
+
+// splitDocText returns the first paragraph of a doc comment joined into one line,
+// and its custom message option lines.
+func splitDocText(text string) (comment string, optionLines []string) {
+	var paragraph []string
+	inFirst := true
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, messageOptionPrefix):
+			optionLines = append(optionLines, line)
+		case len(line) == 0:
+			if len(paragraph) > 0 {
+				inFirst = false
+			}
+		case inFirst:
+			paragraph = append(paragraph, line)
+		}
+	}
+	return strings.Join(paragraph, " "), optionLines
+}
+
+// typesType is the goType of a go/types type.
+type typesType struct {
+	types.Type
+}
+
+// basicKinds maps the basic types of go/types to their reflect kinds.
+var basicKinds = map[types.BasicKind]reflect.Kind{
+	types.Bool:          reflect.Bool,
+	types.Int:           reflect.Int,
+	types.Int8:          reflect.Int8,
+	types.Int16:         reflect.Int16,
+	types.Int32:         reflect.Int32,
+	types.Int64:         reflect.Int64,
+	types.Uint:          reflect.Uint,
+	types.Uint8:         reflect.Uint8,
+	types.Uint16:        reflect.Uint16,
+	types.Uint32:        reflect.Uint32,
+	types.Uint64:        reflect.Uint64,
+	types.Uintptr:       reflect.Uintptr,
+	types.Float32:       reflect.Float32,
+	types.Float64:       reflect.Float64,
+	types.Complex64:     reflect.Complex64,
+	types.Complex128:    reflect.Complex128,
+	types.String:        reflect.String,
+	types.UnsafePointer: reflect.UnsafePointer,
+}
+
+// Kind implements goType.
+func (t typesType) Kind() reflect.Kind {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return basicKinds[u.Kind()]
+	case *types.Pointer:
+		return reflect.Ptr
+	case *types.Slice:
+		return reflect.Slice
+	case *types.Array:
+		return reflect.Array
+	case *types.Map:
+		return reflect.Map
+	case *types.Chan:
+		return reflect.Chan
+	case *types.Signature:
+		return reflect.Func
+	case *types.Struct:
+		return reflect.Struct
+	case *types.Interface:
+		return reflect.Interface
+	default:
+		return reflect.Invalid
+	}
+}
+
+// Name implements goType.
+func (t typesType) Name() string {
+	switch tt := t.Type.(type) {
+	case *types.Named:
+		return tt.Obj().Name()
+	case *types.Basic:
+		return tt.Name()
+	default:
+		return ""
+	}
+}
+
+// PkgPath implements goType.
+func (t typesType) PkgPath() string {
+	if named, ok := t.Type.(*types.Named); ok && named.Obj().Pkg() != nil {
+		return named.Obj().Pkg().Path()
+	}
+	return ""
+}
+
+// String implements goType. Like reflect, types are qualified by package name.
+func (t typesType) String() string {
+	return types.TypeString(t.Type, func(p *types.Package) string {
+		return p.Name()
+	})
+}
+
+// Elem implements goType.
+func (t typesType) Elem() goType {
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return typesType{u.Elem()}
+	case *types.Slice:
+		return typesType{u.Elem()}
+	case *types.Array:
+		return typesType{u.Elem()}
+	case *types.Map:
+		return typesType{u.Elem()}
+	case *types.Chan:
+		return typesType{u.Elem()}
+	default:
+		panic("Elem of invalid type " + t.String())
+	}
+}
+
+// Key implements goType.
+func (t typesType) Key() goType {
+	m, ok := t.Underlying().(*types.Map)
+	if !ok {
+		panic("Key of non-map type " + t.String())
+	}
+	return typesType{m.Key()}
+}
+
+// NumMethod implements goType.
+func (t typesType) NumMethod() int {
+	iface, ok := t.Underlying().(*types.Interface)
+	if !ok {
+		panic("NumMethod of non-interface type " + t.String())
+	}
+	return iface.NumMethods()
+}
+
+// NumField implements goType.
+func (t typesType) NumField() int {
+	return t.structType().NumFields()
+}
+
+// Field implements goType.
+func (t typesType) Field(i int) goField {
+	st := t.structType()
+	field := st.Field(i)
+	return goField{
+		Name:      field.Name(),
+		Type:      typesType{field.Type()},
+		Tag:       st.Tag(i),
+		Exported:  field.Exported(),
+		Anonymous: field.Embedded(),
+	}
+}
+
+// structType returns the underlying struct type.
+func (t typesType) structType() *types.Struct {
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		panic("Field of non-struct type " + t.String())
+	}
+	return st
+}
+
+// ConvertibleTo implements goType. Only types with identical underlying types,
+// ignoring struct tags, are reported convertible, which covers the conversions
+// between struct types.
+func (t typesType) ConvertibleTo(u reflect.Type) bool {
+	return identicalUnderlying(t.Type, u)
+}
+
+// identical reports whether the go/types type t and the reflect type u are the
+// same type: named types by their full name, other types by their structure.
+func identical(t types.Type, u reflect.Type) bool {
+	switch tt := t.(type) {
+	case *types.Named:
+		return tt.Obj().Pkg() != nil && tt.Obj().Pkg().Path() == u.PkgPath() && tt.Obj().Name() == u.Name()
+	case *types.Basic:
+		return len(u.PkgPath()) == 0 && basicKinds[tt.Kind()] == u.Kind()
+	}
+	return len(u.Name()) == 0 && identicalUnderlying(t, u)
+}
+
+// identicalUnderlying reports whether the go/types type t and the reflect type u
+// have identical underlying types, ignoring struct tags.
+func identicalUnderlying(t types.Type, u reflect.Type) bool {
+	switch tt := t.Underlying().(type) {
+	case *types.Basic:
+		return basicKinds[tt.Kind()] == u.Kind()
+	case *types.Pointer:
+		return u.Kind() == reflect.Ptr && identical(tt.Elem(), u.Elem())
+	case *types.Slice:
+		return u.Kind() == reflect.Slice && identical(tt.Elem(), u.Elem())
+	case *types.Array:
+		return u.Kind() == reflect.Array && int64(u.Len()) == tt.Len() && identical(tt.Elem(), u.Elem())
+	case *types.Map:
+		return u.Kind() == reflect.Map && identical(tt.Key(), u.Key()) && identical(tt.Elem(), u.Elem())
+	case *types.Struct:
+		if u.Kind() != reflect.Struct || u.NumField() != tt.NumFields() {
+			return false
+		}
+		for i := 0; i < tt.NumFields(); i++ {
+			f, sf := tt.Field(i), u.Field(i)
+			if f.Name() != sf.Name || f.Embedded() != sf.Anonymous || !identical(f.Type(), sf.Type) {
+				return false
+			}
+			// 未导出字段还需属于同一个包
+			if !f.Exported() && (f.Pkg() == nil || f.Pkg().Path() != sf.PkgPath) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+const multiLineDocSource = `package users
+
+// User is the main user entity.
+// It stores all user profile data.
+//
+// Details after the first paragraph are dropped.
+// pb-option: acme.db.table=users
+type User struct {
+	Name string // the display name
+}
+`
+
+func TestAstTypeDocsMultiLine(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "users.go", multiLineDocSource, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := astTypeDocs(fset, []*ast.File{f}, "example.com/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, ok := docs["example.com/users.User"]
+	if !ok {
+		t.Fatalf("no doc for User in %v", docs)
+	}
+	if want := "User is the main user entity. It stores all user profile data."; doc.comment != want {
+		t.Errorf("comment = %q, want %q", doc.comment, want)
+	}
+	if len(doc.optionLines) != 1 || doc.optionLines[0] != "pb-option: acme.db.table=users" {
+		t.Errorf("option lines = %q", doc.optionLines)
+	}
+	if got := doc.fieldComments["Name"]; got != "the display name" {
+		t.Errorf("field comment = %q", got)
+	}
+}
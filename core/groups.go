@@ -0,0 +1,61 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Structs2PbGroups converts each group of structs to its own ProtoFile, keyed and
+// packaged by the group name, which is the proto package. Fields referencing a
+// struct of another group use its full name and import the file of that group,
+// expected at GroupFileName(group).
+func Structs2PbGroups(groups map[string][]interface{}, opts ...Option) (map[string]*ProtoFile, error) {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if len(name) == 0 {
+			return nil, errors.New("empty group name")
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// 记录每个结构体所属的分组，用于跨文件引用
+	c := newConverter(opts...)
+	packages := make(map[string]string)
+	for _, name := range names {
+		for _, bean := range groups[name] {
+			t, skip, err := c.beanType(bean)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %w", name, err)
+			}
+			if !skip {
+				packages[fullName(reflectType{t})] = name
+			}
+		}
+	}
+
+	files := make(map[string]*ProtoFile, len(groups))
+	for _, name := range names {
+		groupOpts := append(append([]Option{}, opts...), WithPackageName(name), withGroupPackages(packages))
+		file, err := Structs2ProtoFile(groups[name], groupOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+		files[name] = file
+	}
+	return files, nil
+}
+
+// GroupFileName returns the path of the proto file of a package converted by
+// Structs2PbGroups, e.g. example/user.proto for the package example.user.
+func GroupFileName(pkg string) string {
+	return strings.ReplaceAll(pkg, ".", "/") + ".proto"
+}
+
+func withGroupPackages(packages map[string]string) Option {
+	return func(o *options) {
+		o.groupPackages = packages
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// httpRuleTagKey is the struct tag key declaring the HTTP rule of the RPC taking
+	// a struct as request, usually set on a blank field:
+	//
+	//	_ struct{} `http-rule:"GET /v1/users/{id}"`
+	httpRuleTagKey = "http-rule"
+
+	// HTTPRuleImport is the proto file declaring the google.api.http option.
+	HTTPRuleImport = "google/api/annotations.proto"
+)
+
+// HTTPRule maps an RPC to an HTTP method and path for grpc-gateway.
+type HTTPRule struct {
+	Method string
+	Path   string
+}
+
+// ParseHTTPRule parses an HTTP rule such as "GET /v1/users/{id}".
+func ParseHTTPRule(rule string) (HTTPRule, error) {
+	parts := strings.Fields(rule)
+	if len(parts) != 2 {
+		return HTTPRule{}, fmt.Errorf("invalid http rule %q: expected \"<METHOD> <path>\"", rule)
+	}
+	method := strings.ToUpper(parts[0])
+	switch method {
+	case "GET", "PUT", "POST", "DELETE", "PATCH":
+	default:
+		return HTTPRule{}, fmt.Errorf("invalid http rule %q: unsupported method %s", rule, parts[0])
+	}
+	if !strings.HasPrefix(parts[1], "/") {
+		return HTTPRule{}, fmt.Errorf("invalid http rule %q: path must start with /", rule)
+	}
+	return HTTPRule{Method: method, Path: parts[1]}, nil
+}
+
+// httpRuleOf returns the HTTP rule declared by the http-rule tag of a field of the
+// request struct t, if any.
+func httpRuleOf(t reflect.Type) (HTTPRule, bool, error) {
+	for i := 0; i < t.NumField(); i++ {
+		if rule, ok := t.Field(i).Tag.Lookup(httpRuleTagKey); ok {
+			r, err := ParseHTTPRule(rule)
+			return r, true, err
+		}
+	}
+	return HTTPRule{}, false, nil
+}
+
+// PathVariables returns the names of the fields referenced by the path, e.g. id
+// for /v1/users/{id} and user for /v1/{user.name=users/*}.
+func (r HTTPRule) PathVariables() []string {
+	var vars []string
+	path := r.Path
+	for {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			return vars
+		}
+		end := strings.Index(path[start:], "}")
+		if end < 0 {
+			return vars
+		}
+		v := path[start+1 : start+end]
+		if i := strings.IndexAny(v, "=."); i >= 0 {
+			v = v[:i]
+		}
+		vars = append(vars, v)
+		path = path[start+end+1:]
+	}
+}
+
+// Validate checks that the path variables reference fields of the request message.
+func (r HTTPRule) Validate(request Message) error {
+	names := make(map[string]bool)
+	for _, f := range request.allFields() {
+		names[f.Name] = true
+	}
+	for _, v := range r.PathVariables() {
+		if !names[v] {
+			return fmt.Errorf("http rule %s %s: message %s has no field %s", r.Method, r.Path, request.Name, v)
+		}
+	}
+	return nil
+}
+
+// OptionValue returns the value of the google.api.http option. Methods with a
+// request body map the whole request message to it.
+func (r HTTPRule) OptionValue() string {
+	value := fmt.Sprintf("{ %s: %s", strings.ToLower(r.Method), strconv.Quote(r.Path))
+	switch r.Method {
+	case "PUT", "POST", "PATCH":
+		value += ` body: "*"`
+	}
+	return value + " }"
+}
+
+// String returns the google.api.http option of the rule.
+func (r HTTPRule) String() string {
+	return fmt.Sprintf("option (google.api.http) = %s;", r.OptionValue())
+}
@@ -0,0 +1,58 @@
+//go:build integration
+
+package core
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"struct2pb/obj"
+)
+
+// protocCompile writes file to a temporary directory and compiles it with protoc,
+// failing the test if protoc rejects it. The test is skipped without protoc.
+func protocCompile(t *testing.T, file *ProtoFile) {
+	t.Helper()
+	protoc, err := exec.LookPath("protoc")
+	if err != nil {
+		t.Skip("protoc not found on PATH")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.proto")
+	if err := os.WriteFile(path, []byte(file.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(protoc, "--proto_path="+dir, "--descriptor_set_out="+os.DevNull, path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("protoc: %v\n%s\n%s", err, out, file)
+	}
+}
+
+func TestProtocObj(t *testing.T) {
+	file, err := Structs2ProtoFile(obj.List)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocCompile(t, file)
+}
+
+func TestProtocFieldTypes(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{InterfaceFields{}, EnumSlice{}, ParsedUser{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocCompile(t, file)
+}
+
+func TestProtocMessageOptions(t *testing.T) {
+	for _, v := range []SyntaxVersion{Proto3, Proto2, Edition2023} {
+		file, err := messageOptionsFile(t, "acme.db", []string{"pb-option: acme.db.table=users"},
+			WithCustomMessageOption("acme.db.table", 50001), WithSyntax(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		protocCompile(t, file)
+	}
+}
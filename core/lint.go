@@ -0,0 +1,133 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// severity levels of lint diagnostics.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// lint rules checked by the ProtoLinter, following the Google AIP style guide.
+const (
+	RuleMessageNamePascalCase     = "MESSAGE_NAME_PASCAL_CASE"
+	RuleFieldNameLowerSnakeCase   = "FIELD_NAME_LOWER_SNAKE_CASE"
+	RuleEnumNamePascalCase        = "ENUM_NAME_PASCAL_CASE"
+	RuleEnumValueUpperSnakeCase   = "ENUM_VALUE_UPPER_SNAKE_CASE"
+	RuleEnumUnnecessaryAllowAlias = "ENUM_UNNECESSARY_ALLOW_ALIAS"
+	RuleServiceNamePascalCase     = "SERVICE_NAME_PASCAL_CASE"
+	RuleRPCNamePascalCase         = "RPC_NAME_PASCAL_CASE"
+	RuleRPCRequestResponseSuffix  = "RPC_REQUEST_RESPONSE_SUFFIX"
+)
+
+var (
+	pascalCaseRegexp     = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+	lowerSnakeCaseRegexp = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	upperSnakeCaseRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+)
+
+// LintDiagnostic represents a style issue found in a proto file.
+// Message is the name of the message (or enum, or service) containing the issue,
+// and FieldName the name of the field (or enum value, or RPC), if any.
+type LintDiagnostic struct {
+	Message   string
+	FieldName string
+	Rule      string
+	Severity  string
+}
+
+// String returns a string representation of a LintDiagnostic.
+func (d LintDiagnostic) String() string {
+	if len(d.FieldName) > 0 {
+		return fmt.Sprintf("%s: %s.%s: %s", d.Severity, d.Message, d.FieldName, d.Rule)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Message, d.Rule)
+}
+
+// LinterOption configures a ProtoLinter.
+type LinterOption func(*ProtoLinter)
+
+// WithoutRule disables a lint rule.
+func WithoutRule(rule string) LinterOption {
+	return func(l *ProtoLinter) {
+		delete(l.rules, rule)
+	}
+}
+
+// WithRuleSeverity sets the severity reported by a lint rule, enabling it if needed.
+func WithRuleSeverity(rule, severity string) LinterOption {
+	return func(l *ProtoLinter) {
+		l.rules[rule] = severity
+	}
+}
+
+// ProtoLinter checks generated proto files against the Google AIP style guide.
+// It is an optional post-processing step and does not affect the conversion.
+type ProtoLinter struct {
+	// rules maps the enabled rules to their severity.
+	rules map[string]string
+}
+
+// NewProtoLinter creates a new linter with all rules enabled.
+func NewProtoLinter(opts ...LinterOption) *ProtoLinter {
+	l := &ProtoLinter{
+		rules: map[string]string{
+			RuleMessageNamePascalCase:     SeverityError,
+			RuleFieldNameLowerSnakeCase:   SeverityWarning,
+			RuleEnumNamePascalCase:        SeverityError,
+			RuleEnumValueUpperSnakeCase:   SeverityWarning,
+			RuleEnumUnnecessaryAllowAlias: SeverityWarning,
+			RuleServiceNamePascalCase:     SeverityError,
+			RuleRPCNamePascalCase:         SeverityError,
+			RuleRPCRequestResponseSuffix:  SeverityWarning,
+		},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Lint returns the diagnostics for all style issues found in the file.
+func (l *ProtoLinter) Lint(f *ProtoFile) []LintDiagnostic {
+	var diagnostics []LintDiagnostic
+	check := func(rule string, ok bool, message, fieldName string) {
+		severity, enabled := l.rules[rule]
+		if !enabled || ok {
+			return
+		}
+		diagnostics = append(diagnostics, LintDiagnostic{
+			Message:   message,
+			FieldName: fieldName,
+			Rule:      rule,
+			Severity:  severity,
+		})
+	}
+
+	for _, m := range f.Messages {
+		check(RuleMessageNamePascalCase, pascalCaseRegexp.MatchString(m.Name), m.Name, "")
+		for _, field := range m.Fields {
+			check(RuleFieldNameLowerSnakeCase, lowerSnakeCaseRegexp.MatchString(field.Name), m.Name, field.Name)
+		}
+	}
+	for _, e := range f.Enums {
+		check(RuleEnumNamePascalCase, pascalCaseRegexp.MatchString(e.Name), e.Name, "")
+		check(RuleEnumUnnecessaryAllowAlias, !e.AllowAlias || e.HasAliases(), e.Name, "")
+		for _, v := range e.Values {
+			check(RuleEnumValueUpperSnakeCase, upperSnakeCaseRegexp.MatchString(v.Name), e.Name, v.Name)
+		}
+	}
+	for _, s := range f.Services {
+		check(RuleServiceNamePascalCase, pascalCaseRegexp.MatchString(s.Name), s.Name, "")
+		for _, r := range s.RPCs {
+			check(RuleRPCNamePascalCase, pascalCaseRegexp.MatchString(r.Name), s.Name, r.Name)
+			check(RuleRPCRequestResponseSuffix,
+				strings.HasSuffix(r.RequestType, "Request") && strings.HasSuffix(r.ResponseType, "Response"), s.Name, r.Name)
+		}
+	}
+	return diagnostics
+}
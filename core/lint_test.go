@@ -0,0 +1,41 @@
+package core
+
+import "testing"
+
+func TestLintServices(t *testing.T) {
+	file := &ProtoFile{Services: []Service{{
+		Name: "UserService",
+		RPCs: []RPC{
+			{Name: "GetUser", RequestType: "GetUserRequest", ResponseType: "GetUserResponse"},
+			{Name: "DeleteUser", RequestType: "DeleteUserRequest", ResponseType: "User"},
+			{Name: "list_users", RequestType: "ListUsers", ResponseType: "ListUsersResponse"},
+		},
+	}, {
+		Name: "admin",
+	}}}
+	got := NewProtoLinter().Lint(file)
+	want := []LintDiagnostic{
+		{Message: "UserService", FieldName: "DeleteUser", Rule: RuleRPCRequestResponseSuffix, Severity: SeverityWarning},
+		{Message: "UserService", FieldName: "list_users", Rule: RuleRPCNamePascalCase, Severity: SeverityError},
+		{Message: "UserService", FieldName: "list_users", Rule: RuleRPCRequestResponseSuffix, Severity: SeverityWarning},
+		{Message: "admin", Rule: RuleServiceNamePascalCase, Severity: SeverityError},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("diagnostic %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLintRPCSuffixDisabled(t *testing.T) {
+	file := &ProtoFile{Services: []Service{{
+		Name: "UserService",
+		RPCs: []RPC{{Name: "DeleteUser", RequestType: "DeleteUserRequest", ResponseType: "User"}},
+	}}}
+	if got := NewProtoLinter(WithoutRule(RuleRPCRequestResponseSuffix)).Lint(file); len(got) > 0 {
+		t.Errorf("got %v, want none", got)
+	}
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// manifestEntry records how a struct field was mapped to a proto type.
+type manifestEntry struct {
+	Struct    string `json:"struct"`
+	Field     string `json:"field"`
+	GoType    string `json:"goType"`
+	ProtoType string `json:"protoType"`
+	Warning   string `json:"warning,omitempty"`
+}
+
+// warn records a warning about the field being converted.
+func (c *converter) warn(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// record adds the mapping of a struct field to the manifest, together with the
+// warnings raised while converting it.
+func (c *converter) record(structName, fieldName, goType, protoType string) {
+	c.manifest = append(c.manifest, manifestEntry{
+		Struct:    structName,
+		Field:     fieldName,
+		GoType:    goType,
+		ProtoType: protoType,
+		Warning:   strings.Join(c.warnings, "; "),
+	})
+	c.warnings = nil
+}
+
+// writeManifest writes the recorded mappings as a JSON array.
+func (c *converter) writeManifest(w io.Writer) error {
+	entries := c.manifest
+	if entries == nil {
+		entries = []manifestEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
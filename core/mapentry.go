@@ -0,0 +1,63 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MapEntry returns the synthetic message representing the map field f in
+// descriptors, where a map field is a repeated field of that message:
+//
+//	message <Name>Entry {
+//	  option map_entry = true;
+//	  <key> key = 1;
+//	  <value> value = 2;
+//	}
+//
+// The entry is named after the field the way protoc does, which descriptor
+// validation requires. It reports false if f is not a map field.
+func (f MessageField) MapEntry() (Message, bool) {
+	key, value, ok := parseMapType(f.Typ)
+	if !ok {
+		return Message{}, false
+	}
+	return Message{
+		Name:    mapEntryName(f.Name),
+		Options: map[string]string{"map_entry": "true"},
+		Fields: []MessageField{
+			NewMessageField(key, "key", 1, ""),
+			NewMessageField(value, "value", 2, ""),
+		},
+	}, true
+}
+
+// parseMapType splits a map type `map<K, V>` into its key and value types.
+func parseMapType(typ string) (key, value string, ok bool) {
+	if !strings.HasPrefix(typ, pbMap+"<") || !strings.HasSuffix(typ, ">") {
+		return "", "", false
+	}
+	kv := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(typ, pbMap+"<"), ">"), ",", 2)
+	if len(kv) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]), true
+}
+
+// mapEntryName returns the name of the map entry message of a map field: the field
+// name in CamelCase, with underscores removed, followed by Entry.
+func mapEntryName(fieldName string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range fieldName {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + "Entry"
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// NilStrategy controls how nil struct pointers passed as beans are handled. A
+// non-nil pointer, such as new(User), is always converted.
+type NilStrategy int
+
+const (
+	// NilStrategyError fails the conversion with ErrNilBean.
+	NilStrategyError NilStrategy = iota
+	// NilStrategySkip skips nil pointers silently.
+	NilStrategySkip
+	// NilStrategyDereferenceType converts the type pointed to, since only the type
+	// of a bean is needed.
+	NilStrategyDereferenceType
+)
+
+// beanType returns the struct type of a bean, dereferencing pointers. Nil
+// pointers are handled according to the nil strategy, skip reporting that the
+// bean is skipped. A nil interface is always an error, and so is a bean that is not
+// a struct or a pointer to one, with ErrNotAStruct.
+func (c *converter) beanType(bean interface{}) (t reflect.Type, skip bool, err error) {
+	v := reflect.ValueOf(bean)
+	if !v.IsValid() {
+		return nil, false, ErrNilBean
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		switch c.nilStrategy {
+		case NilStrategySkip:
+			return nil, true, nil
+		case NilStrategyDereferenceType:
+			t = v.Type().Elem()
+		default:
+			return nil, false, fmt.Errorf("%s: %w", v.Type(), ErrNilBean)
+		}
+	} else {
+		t = reflect.Indirect(v).Type()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, false, fmt.Errorf("%s: %w", t, ErrNotAStruct)
+	}
+	return t, false, nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Oneof represents a oneof group of a protocol buffer message.
+type Oneof struct {
+	Name    string
+	Comment string
+	Fields  []MessageField
+}
+
+// String returns a string representation of a Oneof.
+func (o Oneof) String() string {
+	return o.stringAtDepth(0, defaultRenderStyle)
+}
+
+// stringAtDepth renders the oneof indented depth times by style.indent.
+func (o Oneof) stringAtDepth(depth int, style renderStyle) string {
+	var buf bytes.Buffer
+	outer := strings.Repeat(style.indent, depth)
+	inner := outer + style.indent
+
+	if len(o.Comment) > 0 {
+		buf.WriteString(fmt.Sprintf("%s// %s\n", outer, o.Comment))
+	}
+	buf.WriteString(fmt.Sprintf("%soneof %s {\n", outer, o.Name))
+	for _, f := range o.Fields {
+		buf.WriteString(f.line(inner, style.commentStyle))
+	}
+	buf.WriteString(outer + "}\n")
+
+	return buf.String()
+}
+
+// OneofRegistry registers the concrete types implementing interfaces, so that
+// fields of these interfaces can be converted to oneofs.
+type OneofRegistry struct {
+	types map[reflect.Type][]reflect.Type
+}
+
+// NewOneofRegistry creates an empty registry.
+func NewOneofRegistry() *OneofRegistry {
+	return &OneofRegistry{types: make(map[reflect.Type][]reflect.Type)}
+}
+
+// Register adds the implementors of the interface type field, given as values
+// such as new(Foo). The interface type is usually obtained with
+// reflect.TypeOf((*Payload)(nil)).Elem().
+func (r *OneofRegistry) Register(field reflect.Type, implementors ...interface{}) {
+	if r.types == nil {
+		r.types = make(map[reflect.Type][]reflect.Type)
+	}
+	for _, impl := range implementors {
+		r.types[field] = append(r.types[field], reflect.TypeOf(impl))
+	}
+}
+
+// implementors returns the registered interface type t and its implementors. A
+// go/types type matches the registered interface type with the same full name.
+func (r *OneofRegistry) implementors(t goType) (reflect.Type, []reflect.Type) {
+	if r == nil || t.Kind() != reflect.Interface {
+		return nil, nil
+	}
+	if rt, ok := t.(reflectType); ok {
+		return rt.Type, r.types[rt.Type]
+	}
+	if len(t.Name()) == 0 {
+		return nil, nil
+	}
+	for iface, implementors := range r.types {
+		if iface.PkgPath() == t.PkgPath() && iface.Name() == t.Name() {
+			return iface, implementors
+		}
+	}
+	return nil, nil
+}
+
+// interface2Oneof converts an interface field to a oneof with a field for each
+// implementor, numbered from index.
+func (c *converter) interface2Oneof(t reflect.Type, implementors []reflect.Type, name string, index int) (Oneof, error) {
+	oneof := Oneof{Name: name}
+	for _, impl := range implementors {
+		if !impl.Implements(t) {
+			return Oneof{}, fmt.Errorf("%s does not implement %s", impl.String(), t.String())
+		}
+		pbType, err := c.goType2PbType(reflectType{impl})
+		if err != nil {
+			return Oneof{}, err
+		}
+		elem := impl
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		oneof.Fields = append(oneof.Fields, NewMessageField(pbType, c.camelLower(elem.Name()), index, ""))
+		index++
+	}
+	return oneof, nil
+}
+
+// interfaceSlice2Wrapper converts the element type t of a slice of interfaces to a
+// wrapper message `<Interface>Wrapper { oneof <interface> { ... } }`, since a
+// oneof cannot be repeated. The slice is then converted to a repeated wrapper.
+func (c *converter) interfaceSlice2Wrapper(t reflect.Type, implementors []reflect.Type) (Message, error) {
+	oneof, err := c.interface2Oneof(t, implementors, c.camelLower(t.Name()), 1)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Name: t.Name() + "Wrapper", Oneofs: []Oneof{oneof}}, nil
+}
+
+// appendField appends f to the fields of msg or, if its struct field has the
+// `pb:"oneof_group=<name>"` directive, to the oneof of that name, creating it on
+// first use. Only pointer fields may join a oneof group, since at most one of
+// them is set.
+func (c *converter) appendField(msg *Message, f MessageField, structTag string, isPtr bool) error {
+	group, ok := c.directives(structTag)["oneof_group"]
+	if !ok {
+		msg.Fields = append(msg.Fields, f)
+		return nil
+	}
+	if len(group) == 0 {
+		return fmt.Errorf("message %s: empty oneof_group of field %s", msg.Name, f.Name)
+	}
+	if !isPtr {
+		return fmt.Errorf("message %s: field %s of oneof_group %s is not a pointer", msg.Name, f.Name, group)
+	}
+	// oneof字段不能带optional
+	f = f.WithType(strings.TrimPrefix(f.Typ, pbOptional+fieldSep))
+	for i := range msg.Oneofs {
+		if msg.Oneofs[i].Name == group {
+			msg.Oneofs[i].Fields = append(msg.Oneofs[i].Fields, f)
+			return nil
+		}
+	}
+	msg.Oneofs = append(msg.Oneofs, Oneof{Name: group, Fields: []MessageField{f}})
+	return nil
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+type Payload interface {
+	isPayload()
+}
+
+type TextPayload struct {
+	Text string
+}
+
+func (*TextPayload) isPayload() {}
+
+type ImagePayload struct {
+	URL string
+}
+
+func (*ImagePayload) isPayload() {}
+
+type Envelope struct {
+	ID      string
+	Payload Payload
+}
+
+func TestInterface2Oneof(t *testing.T) {
+	r := NewOneofRegistry()
+	r.Register(reflect.TypeOf((*Payload)(nil)).Elem(), new(TextPayload), new(ImagePayload))
+	file, err := Structs2ProtoFile([]interface{}{Envelope{}, TextPayload{}, ImagePayload{}}, WithOneofRegistry(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "message Envelope {\n" +
+		"  string id = 1;\n" +
+		"  oneof payload {\n" +
+		"    TextPayload textPayload = 2;\n" +
+		"    ImagePayload imagePayload = 3;\n" +
+		"  }\n" +
+		"}\n"
+	if got := file.Messages[0].String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	compileProto(t, file)
+}
+
+func TestInterface2OneofNotImplemented(t *testing.T) {
+	r := NewOneofRegistry()
+	r.Register(reflect.TypeOf((*Payload)(nil)).Elem(), TextPayload{})
+	if _, err := Structs2ProtoFile([]interface{}{Envelope{}}, WithOneofRegistry(r)); err == nil {
+		t.Error("value type with pointer methods accepted as implementor")
+	}
+}
@@ -0,0 +1,484 @@
+package core
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ArrayBehavior controls how fixed-size arrays are converted.
+type ArrayBehavior int
+
+const (
+	// ArrayBehaviorRepeated converts arrays like slices, e.g. [4]float32 to repeated float.
+	ArrayBehaviorRepeated ArrayBehavior = iota
+	// ArrayBehaviorBytes converts byte arrays to bytes and other arrays to repeated fields.
+	ArrayBehaviorBytes
+	// ArrayBehaviorError converts byte arrays to bytes and fails on other arrays,
+	// since protobuf has no fixed-size array type.
+	ArrayBehaviorError
+)
+
+// defaultMaxDepth is the default nesting depth limit of embedded structs.
+const defaultMaxDepth = 50
+
+// options holds the settings of a conversion.
+type options struct {
+	strictMode bool
+	// wrapperTypes converts pointers to scalars to wrapper types.
+	wrapperTypes bool
+	// oneofRegistry lists the implementors of interface fields converted to oneofs.
+	oneofRegistry *OneofRegistry
+	// arrayBehavior controls how fixed-size arrays are converted.
+	arrayBehavior ArrayBehavior
+	// maxDepth limits the nesting depth of embedded structs.
+	maxDepth int
+	// customMessageOptions maps the fully qualified name of a custom message option
+	// to its extension field number.
+	customMessageOptions map[string]int
+	// messageOptionImports maps the fully qualified name of a custom message option
+	// declared in another proto file to the import path of that file.
+	messageOptionImports map[string]string
+	// importResolver resolves the proto imports of custom go types.
+	importResolver func(reflect.Type) (string, bool)
+	// messageComment and fieldComment replace the comments extracted from the sources.
+	messageComment func(reflect.Type) string
+	fieldComment   func(reflect.StructField) string
+	// manifestWriter receives the manifest of the type mappings, if set.
+	manifestWriter io.Writer
+	// fileOptions holds the file-level options, with their values rendered.
+	fileOptions map[string]string
+	// convertibleTypes maps struct types to proto types by convertibility.
+	convertibleTypes []convertibleType
+	// packageName is the proto package, derived from the go package if empty.
+	packageName string
+	// tagConflictResolver returns the tag of a field whose tag is already used.
+	tagConflictResolver func(existing, incoming MessageField) int
+	// syntax is the syntax or edition of the generated file.
+	syntax SyntaxVersion
+	// tagScheme controls how fields are numbered.
+	tagScheme TagScheme
+	// useWellKnownTypes converts the go types with a google.protobuf counterpart
+	// that is not used by default.
+	useWellKnownTypes bool
+	// acronyms are lowered as a whole at the start of field names.
+	acronyms []string
+	// commentStyle places the comments of fields inline or on their own line.
+	commentStyle CommentStyle
+	// outputFormat is the format of the output, inferred from the file extension
+	// by Struct2PbFile unless outputFormatSet.
+	outputFormat    OutputFormat
+	outputFormatSet bool
+	// protoIndent is the whitespace indenting declarations in blocks, and
+	// protoIndentWidth its number of spaces if set by WithProtoIndentWidth.
+	protoIndent         string
+	protoIndentWidth    int
+	protoIndentWidthSet bool
+	// bufValidate converts validate struct tags to protovalidate rules.
+	bufValidate bool
+	// resource is the AIP-123 resource annotation of the top-level structs.
+	resource *resourceAnnotation
+	// structTagKey is the struct tag key holding the field directives.
+	structTagKey string
+	// nilStrategy handles the nil struct pointers passed as beans.
+	nilStrategy NilStrategy
+	// dryRun makes Struct2PbFile write the file contents to dryRunWriter instead
+	// of the file.
+	dryRun       bool
+	dryRunWriter io.Writer
+	// fieldOrderByCategory orders the fields by category.
+	fieldOrderByCategory bool
+	// rpcNaming derives the request and response types of the RPCs converted by
+	// Interface2Service from the method names.
+	rpcNaming func(methodName string) (requestMsg, responseMsg string)
+	// compression is the compression of binary descriptors.
+	compression CompressionType
+	// weakImports are imported with `import weak`.
+	weakImports []string
+	// disabledBuiltinTypes are the go types converted without their predefined
+	// proto type.
+	disabledBuiltinTypes map[string]bool
+	// groupPackages maps the full name of the structs converted by
+	// Structs2PbGroups to the package of their group.
+	groupPackages map[string]string
+	// protoSyntaxComment writes a header comment with the version of struct2pb
+	// before the syntax statement.
+	protoSyntaxComment bool
+}
+
+// convertibleType maps the struct types convertible to goType to protoType.
+type convertibleType struct {
+	goType    reflect.Type
+	protoType string
+}
+
+// Option configures a conversion.
+type Option func(*options)
+
+func newOptions(opts ...Option) options {
+	o := options{
+		maxDepth:             defaultMaxDepth,
+		customMessageOptions: make(map[string]int),
+		messageOptionImports: make(map[string]string),
+		fileOptions:          make(map[string]string),
+		acronyms:             defaultAcronyms,
+		protoIndent:          indent,
+		structTagKey:         pbTagKey,
+		rpcNaming:            defaultRPCNaming,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithStrictMode makes unsupported types fail the conversion instead of being
+// converted to Any.
+func WithStrictMode(strictMode bool) Option {
+	return func(o *options) {
+		o.strictMode = strictMode
+	}
+}
+
+// WithCustomMessageOption registers a custom message option that can be set with a
+// `pb-option: <optionFQN>=<value>` line in the godoc comment of a struct. The option
+// is declared in an `extend google.protobuf.MessageOptions` block using fieldNumber,
+// so its package must be the package of the generated file; options of other
+// packages are registered with WithImportedMessageOption.
+func WithCustomMessageOption(optionFQN string, fieldNumber int) Option {
+	return func(o *options) {
+		o.customMessageOptions[optionFQN] = fieldNumber
+	}
+}
+
+// WithImportedMessageOption registers a custom message option declared in the proto
+// file importPath, such as WithImportedMessageOption("acme.db.table", "acme/db/options.proto").
+// It is set like the options of WithCustomMessageOption, and importPath is imported
+// instead of declaring the option.
+func WithImportedMessageOption(optionFQN, importPath string) Option {
+	return func(o *options) {
+		o.messageOptionImports[optionFQN] = importPath
+	}
+}
+
+// WithOneofRegistry converts the interface fields whose implementors are registered
+// in r to oneofs.
+func WithOneofRegistry(r *OneofRegistry) Option {
+	return func(o *options) {
+		o.oneofRegistry = r
+	}
+}
+
+// WithFixedArrayBehavior sets how fixed-size arrays are converted. The default is
+// ArrayBehaviorRepeated.
+func WithFixedArrayBehavior(behavior ArrayBehavior) Option {
+	return func(o *options) {
+		o.arrayBehavior = behavior
+	}
+}
+
+// WithMaxDepth limits the nesting depth of embedded structs to n. Deeper structs are
+// converted to an Any field, or fail the conversion in strict mode.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// WithWrapperTypes converts pointers to scalars to the wrapper well-known types,
+// e.g. *string to google.protobuf.StringValue, and imports google/protobuf/wrappers.proto.
+//
+// Wrapper types and proto3 optional fields both track whether a scalar is set.
+// Optional fields keep the plain scalar type in the generated code and on the
+// wire, whereas wrapper types are messages, which cost an extra allocation and
+// encoding layer but are also supported by proto3 runtimes predating optional.
+func WithWrapperTypes(enabled bool) Option {
+	return func(o *options) {
+		o.wrapperTypes = enabled
+	}
+}
+
+// WithGoPackageOption sets the go_package file option, e.g.
+// `option go_package = "github.com/example/proto/gen;gen";`.
+// The package name may be empty if it matches the last element of the import path.
+func WithGoPackageOption(importPath, packageName string) Option {
+	goPackage := importPath
+	if len(packageName) > 0 {
+		goPackage += ";" + packageName
+	}
+	return withFileOption("go_package", strconv.Quote(goPackage))
+}
+
+// WithJavaPackage sets the java_package file option.
+func WithJavaPackage(pkg string) Option {
+	return withFileOption("java_package", strconv.Quote(pkg))
+}
+
+// WithJavaOuterClassname sets the java_outer_classname file option.
+func WithJavaOuterClassname(name string) Option {
+	return withFileOption("java_outer_classname", strconv.Quote(name))
+}
+
+// WithJavaMultipleFiles sets the java_multiple_files file option, generating a
+// java file per top-level message instead of nesting them in the outer class.
+func WithJavaMultipleFiles(enabled bool) Option {
+	return withFileOption("java_multiple_files", strconv.FormatBool(enabled))
+}
+
+// WithObjcClassPrefix sets the objc_class_prefix file option.
+func WithObjcClassPrefix(prefix string) Option {
+	return withFileOption("objc_class_prefix", strconv.Quote(prefix))
+}
+
+// WithCSharpNamespace sets the csharp_namespace file option.
+func WithCSharpNamespace(namespace string) Option {
+	return withFileOption("csharp_namespace", strconv.Quote(namespace))
+}
+
+// WithManifest writes a JSON array describing how each struct field was mapped to w
+// at the end of the conversion, e.g.
+// `{"struct":"obj.Job","field":"CreateTime","goType":"obj.LocalTime","protoType":"int64"}`.
+// Entries also carry a warning when the mapping may be surprising, such as a
+// field converted to Any.
+func WithManifest(w io.Writer) Option {
+	return func(o *options) {
+		o.manifestWriter = w
+	}
+}
+
+// WithImportResolver sets the resolver returning the proto file to import for a
+// custom go type, used for struct fields and by ProtoFile.AddImportForType.
+func WithImportResolver(resolver func(reflect.Type) (string, bool)) Option {
+	return func(o *options) {
+		o.importResolver = resolver
+	}
+}
+
+// WithMessageComment sets the function returning the comment of the message
+// converted from a struct, instead of extracting it from the sources.
+func WithMessageComment(extractor func(reflect.Type) string) Option {
+	return func(o *options) {
+		o.messageComment = extractor
+	}
+}
+
+// WithFieldComment sets the function returning the comment of the field converted
+// from a struct field, instead of extracting it from the sources. When combined
+// with WithMessageComment, the sources are not parsed at all, so pb-option lines
+// are ignored.
+func WithFieldComment(extractor func(reflect.StructField) string) Option {
+	return func(o *options) {
+		o.fieldComment = extractor
+	}
+}
+
+// WithConvertibleType converts the struct types convertible to the type of goType
+// to protoType, like time types are converted to int64, e.g.
+// WithConvertibleType(decimal.Decimal{}, "string"). Types registered first win.
+func WithConvertibleType(goType interface{}, protoType string) Option {
+	return func(o *options) {
+		o.convertibleTypes = append(o.convertibleTypes, convertibleType{reflect.TypeOf(goType), protoType})
+	}
+}
+
+// WithPackageName sets the proto package of the file. By default it is derived
+// from the go package of the first struct with GoPackagePath2ProtoPackage.
+func WithPackageName(name string) Option {
+	return func(o *options) {
+		o.packageName = name
+	}
+}
+
+// WithTagConflictResolver sets the function returning the tag of a field whose tag
+// is already used by a previous field of the message, instead of the next free
+// tag. The resolver runs before the reserved range 19000-19999 is skipped: a tag
+// in that range is moved to 20000. The conversion fails if the final tag is
+// still used.
+func WithTagConflictResolver(fn func(existing, incoming MessageField) (resolvedTag int)) Option {
+	return func(o *options) {
+		o.tagConflictResolver = fn
+	}
+}
+
+// WithSyntax sets the syntax or edition of the generated file, Proto3 by default.
+func WithSyntax(v SyntaxVersion) Option {
+	return func(o *options) {
+		o.syntax = v
+	}
+}
+
+// WithTagNumbering sets how the fields are numbered, TagSchemeSequential by default.
+func WithTagNumbering(scheme TagScheme) Option {
+	return func(o *options) {
+		o.tagScheme = scheme
+	}
+}
+
+// WithWellKnownTypes converts the go types with a google.protobuf counterpart that
+// is not used by default: time.Duration to google.protobuf.Duration instead of
+// int64 nanoseconds, and *interface{} to google.protobuf.NullValue. NullValue
+// only represents JSON null, which is rarely the right choice: an optional field
+// usually expresses the absence of a value better.
+func WithWellKnownTypes(enabled bool) Option {
+	return func(o *options) {
+		o.useWellKnownTypes = enabled
+	}
+}
+
+// WithAcronyms replaces the acronyms lowered as a whole at the start of field
+// names, ID, URL, HTTP, API, UUID and SQL by default.
+func WithAcronyms(acronyms []string) Option {
+	return func(o *options) {
+		o.acronyms = acronyms
+	}
+}
+
+// WithCommentStyle sets where the comments of fields are rendered in the file,
+// CommentStyleInline by default.
+func WithCommentStyle(style CommentStyle) Option {
+	return func(o *options) {
+		o.commentStyle = style
+	}
+}
+
+// WithOutputFormat sets the format of the output of Structs2Pb, Structs2PbOutput
+// and Struct2PbFile.
+func WithOutputFormat(format OutputFormat) Option {
+	return func(o *options) {
+		o.outputFormat = format
+		o.outputFormatSet = true
+	}
+}
+
+// WithProtoIndent sets the whitespace indenting declarations in blocks of the file,
+// two spaces by default. It may only contain spaces and tabs.
+func WithProtoIndent(s string) Option {
+	return func(o *options) {
+		o.protoIndent = s
+		o.protoIndentWidthSet = false
+	}
+}
+
+// WithProtoIndentWidth indents declarations in blocks with n spaces, from 1 to 8,
+// instead of the default two spaces recommended by the style guide.
+func WithProtoIndentWidth(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.protoIndent = strings.Repeat(" ", n)
+		}
+		o.protoIndentWidth = n
+		o.protoIndentWidthSet = true
+	}
+}
+
+// WithBufValidate converts the go-playground/validator rules of `validate` struct
+// tags to protovalidate field options, e.g. `validate:"required,min=5"` on an int
+// field to `[(buf.validate.field).required = true, (buf.validate.field).int64.gte = 5]`.
+// See bufValidateRules for the mapping of each rule.
+func WithBufValidate(enabled bool) Option {
+	return func(o *options) {
+		o.bufValidate = enabled
+	}
+}
+
+// WithResourceAnnotation sets the google.api.resource option of the messages
+// converted from top-level structs, meant for converting a single struct, e.g.
+// WithResourceAnnotation("myapi.example.com/User", "users/{user}"). A struct may
+// declare its own annotation with the pb struct tag of one of its fields:
+//
+//	_ struct{} `pb:"resource_type=myapi.example.com/User,resource_pattern=users/{user}"`
+func WithResourceAnnotation(resourceType, pattern string) Option {
+	return func(o *options) {
+		o.resource = &resourceAnnotation{resourceType, pattern}
+	}
+}
+
+// WithStructTagKey sets the struct tag key holding the field directives, such as
+// tag, map_value_tag and resource_type, pb by default, e.g. with
+// WithStructTagKey("proto") the tag of a field is set with `proto:"tag=3"`.
+func WithStructTagKey(key string) Option {
+	return func(o *options) {
+		o.structTagKey = key
+	}
+}
+
+// WithNilStrategy sets how nil struct pointers passed as beans, such as
+// (*User)(nil), are handled, NilStrategyError by default.
+func WithNilStrategy(strategy NilStrategy) Option {
+	return func(o *options) {
+		o.nilStrategy = strategy
+	}
+}
+
+// WithDryRun makes Struct2PbFile write the contents of the file to w, os.Stdout
+// if w is nil, instead of writing the file, e.g. for CI to check that the
+// committed files are up to date.
+func WithDryRun(w io.Writer) Option {
+	return func(o *options) {
+		o.dryRun = true
+		o.dryRunWriter = w
+	}
+}
+
+// WithFieldOrderByCategory orders the fields of the messages by category: scalars
+// first, then singular messages, maps and repeated fields, keeping the declaration
+// order within a category. With the default TagSchemeSequential, the fields are
+// numbered in that order.
+func WithFieldOrderByCategory(enabled bool) Option {
+	return func(o *options) {
+		o.fieldOrderByCategory = enabled
+	}
+}
+
+// WithRPCNamingConvention sets the function deriving the request and response
+// types of the RPCs converted by Interface2Service from the method names. By
+// default, CreateUser takes CreateUserRequest and returns CreateUserResponse.
+func WithRPCNamingConvention(fn func(methodName string) (requestMsg, responseMsg string)) Option {
+	return func(o *options) {
+		o.rpcNaming = fn
+	}
+}
+
+// WithCompression sets the compression of the binary descriptors written with
+// OutputFormatBinaryDescriptor, CompressionTypeNone by default. The other output
+// formats are not compressed.
+func WithCompression(compression CompressionType) Option {
+	return func(o *options) {
+		o.compression = compression
+	}
+}
+
+// WithWeakImport imports protoPath with `import weak "<protoPath>";`, whose
+// absence does not fail the compilation, instead of a normal import.
+func WithWeakImport(protoPath string) Option {
+	return func(o *options) {
+		o.weakImports = append(o.weakImports, protoPath)
+	}
+}
+
+// WithoutBuiltinType disables the predefined proto type of a go type, given by its
+// full name, such as github.com/shopspring/decimal.Decimal converted to string,
+// so that it is converted like other structs.
+func WithoutBuiltinType(goType string) Option {
+	return func(o *options) {
+		if o.disabledBuiltinTypes == nil {
+			o.disabledBuiltinTypes = make(map[string]bool)
+		}
+		o.disabledBuiltinTypes[goType] = true
+	}
+}
+
+// WithProtoSyntaxComment writes the header comment `// Generated by struct2pb
+// <Version()>` before the syntax statement of the file.
+func WithProtoSyntaxComment(enabled bool) Option {
+	return func(o *options) {
+		o.protoSyntaxComment = enabled
+	}
+}
+
+func withFileOption(name, value string) Option {
+	return func(o *options) {
+		o.fileOptions[name] = value
+	}
+}
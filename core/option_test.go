@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type Table struct {
+	Name string
+}
+
+// messageOptionsFile converts Table to a file of package pkg, as if its comment
+// had the option lines.
+func messageOptionsFile(t *testing.T, pkg string, optionLines []string, opts ...Option) (*ProtoFile, error) {
+	t.Helper()
+	c := newConverter(append(opts, WithPackageName(pkg))...)
+	c.typeDocs["struct2pb/core.Table"] = typeDoc{optionLines: optionLines}
+	message, err := c.struct2PbField(context.Background(), reflectType{reflect.TypeOf(Table{})}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := ProtoFile{Messages: []Message{message}}
+	if err := c.finishFile(&file, ""); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func TestMessageOptionsExtendDottedName(t *testing.T) {
+	file, err := messageOptionsFile(t, "acme.db", []string{"pb-option: acme.db.table=users"},
+		WithCustomMessageOption("acme.db.table", 50001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := file.Messages[0].Options["(acme.db.table)"]; got != `"users"` {
+		t.Errorf("option = %q, want %q", got, `"users"`)
+	}
+	desc := compileProto(t, file)
+	ext := desc.Extensions().ByName("table")
+	if ext == nil {
+		t.Fatalf("extension table not declared:\n%s", file)
+	}
+	if ext.FullName() != "acme.db.table" || ext.ContainingMessage().FullName() != "google.protobuf.MessageOptions" {
+		t.Errorf("extension %s of %s", ext.FullName(), ext.ContainingMessage().FullName())
+	}
+	if ext.Kind() != protoreflect.StringKind || ext.Number() != 50001 {
+		t.Errorf("extension kind %v number %d", ext.Kind(), ext.Number())
+	}
+}
+
+func TestMessageOptionsExtendOtherPackage(t *testing.T) {
+	lines := []string{"pb-option: a.table=users, b.table=accounts"}
+	if _, err := messageOptionsFile(t, "a", lines,
+		WithCustomMessageOption("a.table", 50001), WithCustomMessageOption("b.table", 50002)); err == nil {
+		t.Error("option b.table declared in package a")
+	}
+
+	file, err := messageOptionsFile(t, "a", lines,
+		WithCustomMessageOption("a.table", 50001), WithImportedMessageOption("b.table", "b/options.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := file.String()
+	if !strings.Contains(s, `import "b/options.proto";`) {
+		t.Errorf("b/options.proto not imported:\n%s", s)
+	}
+	if len(file.Extends) != 1 || len(file.Extends[0].Fields) != 1 || file.Extends[0].Fields[0].Tag() != 50001 {
+		t.Errorf("extends = %v, want only a.table", file.Extends)
+	}
+}
+
+func TestProto2Extend(t *testing.T) {
+	file, err := messageOptionsFile(t, "acme.db", []string{"pb-option: acme.db.table=users"},
+		WithCustomMessageOption("acme.db.table", 50001), WithSyntax(Proto2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Extends[0].Fields = append(file.Extends[0].Fields, NewMessageField("repeated string", "aliases", 50002, ""))
+	s := file.String()
+	for _, want := range []string{
+		`syntax = "proto2";`,
+		"extend google.protobuf.MessageOptions {\n  optional string table = 50001;\n  repeated string aliases = 50002;\n}",
+		"optional string name = 1;",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q in:\n%s", want, s)
+		}
+	}
+}
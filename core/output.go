@@ -0,0 +1,191 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	// 注册生成文件导入的well-known types，以便将其加入描述符集合
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// OutputFormat is the format a ProtoFile is written in.
+type OutputFormat int
+
+const (
+	// OutputFormatTextProto writes the text of the .proto file.
+	OutputFormatTextProto OutputFormat = iota
+	// OutputFormatJSONDescriptor writes the FileDescriptorProto of the file as JSON.
+	OutputFormatJSONDescriptor
+	// OutputFormatBinaryDescriptor writes a FileDescriptorSet of the file and its
+	// dependencies in the protobuf wire format, like protoc --include_imports
+	// --descriptor_set_out.
+	OutputFormatBinaryDescriptor
+)
+
+// CompressionType is the compression of the binary descriptors written by
+// Structs2Pb, Structs2PbOutput and Struct2PbFile.
+type CompressionType int
+
+const (
+	// CompressionTypeNone writes binary descriptors uncompressed.
+	CompressionTypeNone CompressionType = iota
+	// CompressionTypeGzip writes binary descriptors as a gzip stream, as accepted by
+	// schema registries taking gzip-compressed descriptor sets.
+	CompressionTypeGzip
+)
+
+// compress compresses data as set by compression.
+func compress(data []byte, compression CompressionType) ([]byte, error) {
+	switch compression {
+	case CompressionTypeNone:
+		return data, nil
+	case CompressionTypeGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression type: %d", compression)
+	}
+}
+
+// outputFormatExts maps the file extensions to the format they imply.
+var outputFormatExts = map[string]OutputFormat{
+	".proto": OutputFormatTextProto,
+	".json":  OutputFormatJSONDescriptor,
+	".pb":    OutputFormatBinaryDescriptor,
+	".binpb": OutputFormatBinaryDescriptor,
+	".desc":  OutputFormatBinaryDescriptor,
+}
+
+// Marshal validates the file and encodes it in format. Descriptors are named name,
+// the path of the file relative to the proto root.
+func (f ProtoFile) Marshal(format OutputFormat, name string) ([]byte, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if format == OutputFormatTextProto {
+		return []byte(f.String()), nil
+	}
+	d, err := f.ToFileDescriptorProto(name)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case OutputFormatJSONDescriptor:
+		return protojson.MarshalOptions{Multiline: true}.Marshal(d)
+	case OutputFormatBinaryDescriptor:
+		return proto.Marshal(fileDescriptorSet(d))
+	default:
+		return nil, fmt.Errorf("unsupported output format: %d", format)
+	}
+}
+
+// fileDescriptorSet returns the set of the file d preceded by its dependencies,
+// each after its own dependencies. Only the dependencies linked into the program,
+// such as the well-known types, can be included; the others are left out.
+func fileDescriptorSet(d *descriptorpb.FileDescriptorProto) *descriptorpb.FileDescriptorSet {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := map[string]bool{d.GetName(): true}
+	var add func(path string)
+	add = func(path string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+		if err != nil {
+			return
+		}
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).Path())
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, dep := range d.Dependency {
+		add(dep)
+	}
+	set.File = append(set.File, d)
+	return set
+}
+
+// Structs2PbOutput converts the structs to a ProtoFile encoded in the format set by
+// WithOutputFormat, the text of the .proto file by default. Descriptors are named
+// after the package of the file, and binary descriptors compressed as set by
+// WithCompression.
+func Structs2PbOutput(beans []interface{}, opts ...Option) ([]byte, error) {
+	file, err := Structs2ProtoFile(beans, opts...)
+	if err != nil {
+		return nil, err
+	}
+	name := "struct2pb.proto"
+	if len(file.Package) > 0 {
+		name = strings.ReplaceAll(file.Package, ".", "/") + ".proto"
+	}
+	o := newOptions(opts...)
+	data, err := file.Marshal(o.outputFormat, name)
+	if err != nil || o.outputFormat != OutputFormatBinaryDescriptor {
+		return data, err
+	}
+	return compress(data, o.compression)
+}
+
+// Struct2PbFile converts the structs to a ProtoFile written to path. Unless set by
+// WithOutputFormat, the format is inferred from the extension of path: .json for
+// OutputFormatJSONDescriptor, .pb, .binpb or .desc for OutputFormatBinaryDescriptor,
+// and the text of the .proto file otherwise. Descriptors are named after the base
+// name of path with the .proto extension, and binary descriptors compressed as set
+// by WithCompression. With WithDryRun, the contents are written
+// to the dry run writer and path is left untouched.
+func Struct2PbFile(path string, beans []interface{}, opts ...Option) error {
+	file, err := Structs2ProtoFile(beans, opts...)
+	if err != nil {
+		return err
+	}
+	o := newOptions(opts...)
+	ext := filepath.Ext(path)
+	format := o.outputFormat
+	if !o.outputFormatSet {
+		format = outputFormatExts[ext]
+	}
+	data, err := file.Marshal(format, strings.TrimSuffix(filepath.Base(path), ext)+".proto")
+	if err != nil {
+		return err
+	}
+	if format == OutputFormatBinaryDescriptor {
+		if data, err = compress(data, o.compression); err != nil {
+			return err
+		}
+	}
+	if o.dryRun {
+		w := o.dryRunWriter
+		if w == nil {
+			w = os.Stdout
+		}
+		_, err := w.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
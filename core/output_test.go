@@ -0,0 +1,78 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestStructs2PbOutputDescriptorSet(t *testing.T) {
+	for _, compression := range []CompressionType{CompressionTypeNone, CompressionTypeGzip} {
+		data, err := Structs2PbOutput([]interface{}{InterfaceFields{}},
+			WithOutputFormat(OutputFormatBinaryDescriptor), WithCompression(compression), WithPackageName("acme.test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compression == CompressionTypeGzip {
+			r, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, err = io.ReadAll(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		set := &descriptorpb.FileDescriptorSet{}
+		if err := proto.Unmarshal(data, set); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, f := range set.File {
+			names = append(names, f.GetName())
+		}
+		if len(names) != 2 || names[0] != "google/protobuf/any.proto" || names[1] != "acme/test.proto" {
+			t.Errorf("files = %v, want [google/protobuf/any.proto acme/test.proto]", names)
+		}
+		if _, err := protodesc.NewFiles(set); err != nil {
+			t.Errorf("descriptor set does not resolve: %v", err)
+		}
+	}
+}
+
+func TestStructs2PbOutputFormat(t *testing.T) {
+	text, err := Structs2Pb(false, FirstMessage{}, WithPackageName("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "package acme;") || !strings.Contains(text, "message FirstMessage {") {
+		t.Errorf("text output:\n%s", text)
+	}
+
+	out, err := Structs2Pb(false, FirstMessage{}, WithPackageName("acme"), WithOutputFormat(OutputFormatJSONDescriptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &descriptorpb.FileDescriptorProto{}
+	if err := protojson.Unmarshal([]byte(out), d); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if d.GetPackage() != "acme" || len(d.MessageType) != 1 || d.MessageType[0].GetName() != "FirstMessage" {
+		t.Errorf("descriptor = %v", d)
+	}
+
+	out, err = Structs2Pb(false, FirstMessage{}, WithOutputFormat(OutputFormatBinaryDescriptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal([]byte(out), set); err != nil || len(set.File) != 1 {
+		t.Errorf("descriptor set = %v, %v", set, err)
+	}
+}
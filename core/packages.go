@@ -0,0 +1,231 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// packagesLoadMode is the information loaded by Packages2ProtoFile: the types of
+// the packages, and their syntax trees for the comments. The dependencies are
+// type-checked from source too, which does not depend on the export data format
+// of the go command.
+const packagesLoadMode = packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax
+
+// Packages2ProtoFile loads the packages by import path with go/packages and
+// converts their structs to a ProtoFile, without the caller having to import them.
+// Only the structs named in structNames are converted, in that order; if it is
+// empty, all exported structs are converted. Comments are read from the package
+// sources. The options taking reflect types, WithImportResolver, WithMessageComment
+// and WithFieldComment, cannot be applied to the loaded types and fail the
+// conversion with ErrReflectOnlyOption.
+func Packages2ProtoFile(pkgPaths []string, structNames []string, opts ...Option) (*ProtoFile, error) {
+	c := newConverter(opts...)
+	if err := c.checkTypesOptions(); err != nil {
+		return nil, err
+	}
+	pkgs, err := packages.Load(&packages.Config{Mode: packagesLoadMode}, pkgPaths...)
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*packages.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, pkg.Errors[0]
+		}
+		byPath[pkg.PkgPath] = pkg
+	}
+
+	var (
+		all   []*types.Named
+		named = make(map[string]*types.Named)
+	)
+	for _, path := range pkgPaths {
+		pkg, ok := byPath[path]
+		if !ok {
+			return nil, fmt.Errorf("package %s not found", path)
+		}
+		docs, err := astTypeDocs(pkg.Fset, pkg.Syntax, path)
+		if err != nil {
+			return nil, err
+		}
+		for name, doc := range docs {
+			c.typeDocs[name] = doc
+		}
+		c.docPackages[path] = true
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !tn.Exported() {
+				continue
+			}
+			t, ok := tn.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			if _, ok := t.Underlying().(*types.Struct); !ok {
+				continue
+			}
+			if _, ok := named[name]; !ok {
+				named[name] = t
+			}
+			all = append(all, t)
+		}
+	}
+
+	selected := all
+	if len(structNames) > 0 {
+		selected = make([]*types.Named, 0, len(structNames))
+		for _, name := range structNames {
+			t, ok := named[name]
+			if !ok {
+				return nil, fmt.Errorf("struct %s not found in packages %v", name, pkgPaths)
+			}
+			selected = append(selected, t)
+		}
+	}
+
+	file := ProtoFile{importResolver: c.importResolver}
+	if len(c.fileOptions) > 0 {
+		file.Options = c.fileOptions
+	}
+	for _, t := range selected {
+		message, err := c.struct2PbField(context.Background(), typesType{t}, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		file.Messages = append(file.Messages, message)
+	}
+	var pkgPath string
+	if len(selected) > 0 {
+		pkgPath = selected[0].Obj().Pkg().Path()
+	}
+	if err := c.finishFile(&file, pkgPath); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// TypedStruct2PbMessage converts a named struct type from the go/types type-checker
+// to a Message, for tools that already loaded the package and have no value to
+// reflect on. The type-checker keeps no comments, so the message has none. Like
+// Packages2ProtoFile, it fails with ErrReflectOnlyOption if an option taking reflect
+// types is set.
+func TypedStruct2PbMessage(named *types.Named, opts ...Option) (*Message, error) {
+	c := newConverter(opts...)
+	if err := c.checkTypesOptions(); err != nil {
+		return nil, err
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return nil, fmt.Errorf("%s: %w", named.String(), ErrNotAStruct)
+	}
+	message, err := c.struct2PbField(context.Background(), typesType{named}, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// checkTypesOptions returns an error if an option taking reflect types is set,
+// since go/types types have no reflect type to pass to it.
+func (c *converter) checkTypesOptions() error {
+	switch {
+	case c.importResolver != nil:
+		return fmt.Errorf("WithImportResolver: %w", ErrReflectOnlyOption)
+	case c.messageComment != nil:
+		return fmt.Errorf("WithMessageComment: %w", ErrReflectOnlyOption)
+	case c.fieldComment != nil:
+		return fmt.Errorf("WithFieldComment: %w", ErrReflectOnlyOption)
+	default:
+		return nil
+	}
+}
+
+// parsePackage parses the go files of the package with the given import path,
+// with their comments.
+func parsePackage(ctx context.Context, fset *token.FileSet, path string) ([]*ast.File, error) {
+	dir, err := packageDir(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	bp, err := build.Default.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*ast.File, 0, len(bp.GoFiles))
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(bp.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// packageDir returns the directory of the package with the given import path.
+// The packages of the main module and of the standard library are found without
+// running the go command; other packages are looked up with go list, which is
+// killed if ctx is done.
+func packageDir(ctx context.Context, path string) (string, error) {
+	if modDir, modPath, ok := mainModule(); ok {
+		if path == modPath {
+			return modDir, nil
+		}
+		if strings.HasPrefix(path, modPath+"/") {
+			return filepath.Join(modDir, filepath.FromSlash(strings.TrimPrefix(path, modPath+"/"))), nil
+		}
+	}
+	// 标准库的包路径第一段不含点号
+	if first := strings.SplitN(path, "/", 2)[0]; !strings.Contains(first, ".") {
+		dir := filepath.Join(runtime.GOROOT(), "src", filepath.FromSlash(path))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+	out, err := exec.CommandContext(ctx, "go", "list", "-find", "-f", "{{.Dir}}", path).Output()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		return "", fmt.Errorf("go list %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// mainModule returns the directory and path of the module containing the working
+// directory, read from the first go.mod found walking up from it.
+func mainModule() (dir, path string, ok bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", "", false
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) >= 2 && fields[0] == "module" {
+					return dir, strings.Trim(fields[1], `"`), true
+				}
+			}
+			return "", "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"struct2pb/obj"
+)
+
+func TestPackages2ProtoFileMatchesReflect(t *testing.T) {
+	opts := []Option{WithConvertibleType(time.Time{}, "int64")}
+	want, err := Structs2ProtoFile(obj.List, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Packages2ProtoFile([]string{"struct2pb/obj"}, []string{"User", "Job"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("Packages2ProtoFile:\n%s\nStructs2ProtoFile:\n%s", got, want)
+	}
+}
+
+func TestPackages2ProtoFileReflectOnlyOption(t *testing.T) {
+	_, err := Packages2ProtoFile([]string{"struct2pb/obj"}, nil, WithFieldComment(func(reflect.StructField) string { return "" }))
+	if !errors.Is(err, ErrReflectOnlyOption) {
+		t.Errorf("err = %v, want ErrReflectOnlyOption", err)
+	}
+}
+
+func TestPackageDirWithoutGoCommand(t *testing.T) {
+	t.Setenv("PATH", "")
+	for _, path := range []string{"struct2pb/obj", "net/http"} {
+		dir, err := packageDir(context.Background(), path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if filepath.Base(dir) != filepath.Base(path) {
+			t.Errorf("%s: dir = %s", path, dir)
+		}
+	}
+}
+
+func TestPackageDirCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := packageDir(ctx, "example.com/not/found"); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,492 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind is the kind of a token of proto text.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenSymbol
+	tokenComment
+)
+
+// protoToken is a lexical token of proto text.
+type protoToken struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// tokenize splits proto text into tokens. Comments are kept as tokens whose text
+// is the trimmed comment content.
+func tokenize(text string) ([]protoToken, error) {
+	var tokens []protoToken
+	line := 1
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			start := i + 2
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			tokens = append(tokens, protoToken{tokenComment, strings.TrimSpace(string(runes[start:i])), line})
+		case r == '"':
+			start := i
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			i++
+			tokens = append(tokens, protoToken{tokenString, string(runes[start:i]), line})
+		case unicode.IsLetter(r) || r == '_' || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, protoToken{tokenIdent, string(runes[start:i]), line})
+		case unicode.IsDigit(r) || r == '-':
+			start := i
+			for i++; i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.'); i++ {
+			}
+			tokens = append(tokens, protoToken{tokenNumber, string(runes[start:i]), line})
+		case strings.ContainsRune("{}=;:<>,()[]", r):
+			tokens = append(tokens, protoToken{tokenSymbol, string(r), line})
+			i++
+		default:
+			return nil, fmt.Errorf("line %d: unexpected character %q", line, r)
+		}
+	}
+	return append(tokens, protoToken{tokenEOF, "", line}), nil
+}
+
+// protoParser parses the subset of proto syntax generated by this package.
+type protoParser struct {
+	tokens []protoToken
+	pos    int
+}
+
+// peek returns the next token.
+func (p *protoParser) peek() protoToken {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *protoParser) next() protoToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// expect consumes the next token and checks that it has the given kind and, if
+// text is not empty, the given text.
+func (p *protoParser) expect(kind tokenKind, text string) (protoToken, error) {
+	t := p.next()
+	if t.kind != kind || (len(text) > 0 && t.text != text) {
+		want := text
+		if len(want) == 0 {
+			want = kind.String()
+		}
+		if t.kind == tokenEOF {
+			return t, fmt.Errorf("line %d: expected %s, got end of input", t.line, want)
+		}
+		return t, fmt.Errorf("line %d: expected %s, got %q", t.line, want, t.text)
+	}
+	return t, nil
+}
+
+// String returns the name of a token kind.
+func (k tokenKind) String() string {
+	switch k {
+	case tokenIdent:
+		return "identifier"
+	case tokenNumber:
+		return "number"
+	case tokenString:
+		return "string"
+	case tokenSymbol:
+		return "symbol"
+	case tokenComment:
+		return "comment"
+	default:
+		return "end of input"
+	}
+}
+
+// comments consumes the consecutive comment tokens and returns them joined by sep.
+func (p *protoParser) comments(sep string) string {
+	var list []string
+	for p.peek().kind == tokenComment {
+		list = append(list, p.next().text)
+	}
+	return strings.Join(list, sep)
+}
+
+// isNext reports whether the next token has the given kind and text.
+func (p *protoParser) isNext(kind tokenKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && t.text == text
+}
+
+// ParseProtoMessage parses the text of a single message, as generated by
+// Message.String, into a Message. Options, including message values in braces,
+// reserved tags and names, singular, optional, repeated and map fields with their
+// field options, oneofs and nested messages are supported; enums and extensions
+// are not. The comments of messages and fields are kept, those of oneofs are
+// only kept on a single line.
+func ParseProtoMessage(text string) (*Message, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{tokens: tokens}
+
+	m, err := p.parseMessage(p.comments("\n"))
+	if err != nil {
+		return nil, err
+	}
+	p.comments(" ")
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("line %d: unexpected %q after message", t.line, t.text)
+	}
+	return &m, nil
+}
+
+// parseMessage parses `message <name> { ... }`, with its nested messages.
+func (p *protoParser) parseMessage(comment string) (Message, error) {
+	m := Message{Comment: comment}
+	if _, err := p.expect(tokenIdent, "message"); err != nil {
+		return Message{}, err
+	}
+	name, err := p.expect(tokenIdent, "")
+	if err != nil {
+		return Message{}, err
+	}
+	m.Name = name.text
+	if _, err := p.expect(tokenSymbol, "{"); err != nil {
+		return Message{}, err
+	}
+	for {
+		leading := p.comments("\n")
+		switch {
+		case p.isNext(tokenSymbol, "}"):
+			p.next()
+			return m, nil
+		case p.isNext(tokenIdent, "option"):
+			if err := p.parseOption(&m); err != nil {
+				return Message{}, err
+			}
+		case p.isNext(tokenIdent, "reserved"):
+			if err := p.parseReserved(&m); err != nil {
+				return Message{}, err
+			}
+		case p.isNext(tokenIdent, "message"):
+			nested, err := p.parseMessage(leading)
+			if err != nil {
+				return Message{}, err
+			}
+			m.NestedMessages = append(m.NestedMessages, nested)
+		case p.isNext(tokenIdent, "oneof"):
+			o, err := p.parseOneof(strings.ReplaceAll(leading, "\n", " "))
+			if err != nil {
+				return Message{}, err
+			}
+			m.Oneofs = append(m.Oneofs, o)
+		default:
+			f, err := p.parseCommentedField(strings.ReplaceAll(leading, "\n", " "))
+			if err != nil {
+				return Message{}, err
+			}
+			m.Fields = append(m.Fields, f)
+		}
+	}
+}
+
+// parseOneof parses `oneof <name> { <fields> }`.
+func (p *protoParser) parseOneof(comment string) (Oneof, error) {
+	p.next()
+	name, err := p.expect(tokenIdent, "")
+	if err != nil {
+		return Oneof{}, err
+	}
+	if _, err := p.expect(tokenSymbol, "{"); err != nil {
+		return Oneof{}, err
+	}
+	o := Oneof{Name: name.text, Comment: comment}
+	for {
+		leading := p.comments(" ")
+		if p.isNext(tokenSymbol, "}") {
+			p.next()
+			return o, nil
+		}
+		f, err := p.parseCommentedField(leading)
+		if err != nil {
+			return Oneof{}, err
+		}
+		o.Fields = append(o.Fields, f)
+	}
+}
+
+// parseCommentedField parses a field and its trailing comment, which takes
+// precedence over the leading comment.
+func (p *protoParser) parseCommentedField(leading string) (MessageField, error) {
+	f, err := p.parseField()
+	if err != nil {
+		return MessageField{}, err
+	}
+	// 行尾注释优先于前置注释
+	if c := p.peek(); c.kind == tokenComment && c.line == p.tokens[p.pos-1].line {
+		f.Comment = p.next().text
+	} else {
+		f.Comment = leading
+	}
+	return f, nil
+}
+
+// parseReserved parses `reserved <tag or range>, ...;` or `reserved "<name>", ...;`,
+// with bare names as in editions.
+func (p *protoParser) parseReserved(m *Message) error {
+	p.next()
+	for {
+		t := p.next()
+		switch t.kind {
+		case tokenString:
+			name, err := strconv.Unquote(t.text)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid reserved name %s", t.line, t.text)
+			}
+			m.ReservedNames = append(m.ReservedNames, name)
+		case tokenIdent:
+			m.ReservedNames = append(m.ReservedNames, t.text)
+		case tokenNumber:
+			start, err := strconv.Atoi(t.text)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid reserved tag %q", t.line, t.text)
+			}
+			if !p.isNext(tokenIdent, "to") {
+				m.ReservedTags = append(m.ReservedTags, start)
+				break
+			}
+			p.next()
+			endToken, err := p.expect(tokenNumber, "")
+			if err != nil {
+				return err
+			}
+			end, err := strconv.Atoi(endToken.text)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid reserved tag %q", endToken.line, endToken.text)
+			}
+			m.ReservedTagRanges = append(m.ReservedTagRanges, [2]int{start, end})
+		default:
+			return fmt.Errorf("line %d: invalid reserved value %q", t.line, t.text)
+		}
+		if p.isNext(tokenSymbol, ";") {
+			p.next()
+			return nil
+		}
+		if _, err := p.expect(tokenSymbol, ","); err != nil {
+			return err
+		}
+	}
+}
+
+// parseOption parses `option <name> = <value>;` into the options of m, where the
+// value may be a message value in braces.
+func (p *protoParser) parseOption(m *Message) error {
+	p.next()
+	var name string
+	if p.isNext(tokenSymbol, "(") {
+		p.next()
+		ext, err := p.expect(tokenIdent, "")
+		if err != nil {
+			return err
+		}
+		if _, err := p.expect(tokenSymbol, ")"); err != nil {
+			return err
+		}
+		name = "(" + ext.text + ")"
+	} else {
+		t, err := p.expect(tokenIdent, "")
+		if err != nil {
+			return err
+		}
+		name = t.text
+	}
+	if _, err := p.expect(tokenSymbol, "="); err != nil {
+		return err
+	}
+	value, err := p.parseOptionValue()
+	if err != nil {
+		return fmt.Errorf("option %s: %w", name, err)
+	}
+	if _, err := p.expect(tokenSymbol, ";"); err != nil {
+		return err
+	}
+	if name == "message_set_wire_format" {
+		m.MessageSetWireFormat = value == "true"
+		return nil
+	}
+	if m.Options == nil {
+		m.Options = make(map[string]string)
+	}
+	m.Options[name] = value
+	return nil
+}
+
+// parseField parses `[repeated|optional] <type> <name> = <tag> [<options>];`.
+func (p *protoParser) parseField() (MessageField, error) {
+	typ, err := p.parseType()
+	if err != nil {
+		return MessageField{}, err
+	}
+	name, err := p.expect(tokenIdent, "")
+	if err != nil {
+		return MessageField{}, err
+	}
+	if _, err := p.expect(tokenSymbol, "="); err != nil {
+		return MessageField{}, err
+	}
+	number, err := p.expect(tokenNumber, "")
+	if err != nil {
+		return MessageField{}, err
+	}
+	tag, err := strconv.Atoi(number.text)
+	if err != nil {
+		return MessageField{}, fmt.Errorf("line %d: invalid tag %q", number.line, number.text)
+	}
+	var opts []FieldOption
+	if p.isNext(tokenSymbol, "[") {
+		p.next()
+		if opts, err = p.parseFieldOptions(); err != nil {
+			return MessageField{}, err
+		}
+	}
+	if _, err := p.expect(tokenSymbol, ";"); err != nil {
+		return MessageField{}, err
+	}
+	return NewMessageFieldWithOptions(typ, name.text, tag, "", opts...), nil
+}
+
+// parseFieldOptions parses `<name> = <value>, ...]`, after the opening bracket.
+// Names such as (validate.rules).string.min_len and values in braces are kept
+// verbatim.
+func (p *protoParser) parseFieldOptions() ([]FieldOption, error) {
+	var opts []FieldOption
+	for {
+		var name strings.Builder
+		for !p.isNext(tokenSymbol, "=") {
+			t := p.next()
+			if t.kind != tokenIdent && !(t.kind == tokenSymbol && (t.text == "(" || t.text == ")")) {
+				return nil, fmt.Errorf("line %d: invalid field option name %q", t.line, t.text)
+			}
+			name.WriteString(t.text)
+		}
+		p.next()
+		value, err := p.parseOptionValue()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, fieldOption(name.String()+" = "+value))
+		t := p.next()
+		if t.kind == tokenSymbol && t.text == "]" {
+			return opts, nil
+		}
+		if t.kind != tokenSymbol || t.text != "," {
+			return nil, fmt.Errorf("line %d: expected , or ], got %q", t.line, t.text)
+		}
+	}
+}
+
+// parseOptionValue parses a scalar option value or a message value in braces,
+// such as `{ type: "example.com/User" pattern: "users/{user}" }`, whose tokens
+// are joined by spaces, except before `:`, `,`, `;` and `]` and after `[`.
+func (p *protoParser) parseOptionValue() (string, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenIdent || t.kind == tokenNumber || t.kind == tokenString:
+		return t.text, nil
+	case t.kind == tokenSymbol && t.text == "{":
+		var value strings.Builder
+		value.WriteString(t.text)
+		prev := t
+		for depth := 1; depth > 0; {
+			t := p.next()
+			switch {
+			case t.kind == tokenEOF:
+				return "", fmt.Errorf("line %d: unterminated option value", t.line)
+			case t.kind == tokenComment:
+				continue
+			case t.kind == tokenSymbol && t.text == "{":
+				depth++
+			case t.kind == tokenSymbol && t.text == "}":
+				depth--
+			}
+			if !(t.kind == tokenSymbol && strings.Contains(":,;]", t.text)) && !(prev.kind == tokenSymbol && prev.text == "[") {
+				value.WriteString(" ")
+			}
+			value.WriteString(t.text)
+			prev = t
+		}
+		return value.String(), nil
+	default:
+		return "", fmt.Errorf("line %d: invalid option value %q", t.line, t.text)
+	}
+}
+
+// parseType parses a field type, rendered the way goType2PbType does.
+func (p *protoParser) parseType() (string, error) {
+	t, err := p.expect(tokenIdent, "")
+	if err != nil {
+		return "", err
+	}
+	switch t.text {
+	case pbArray, pbOptional:
+		elem, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		return t.text + fieldSep + elem, nil
+	case pbMap:
+		if _, err := p.expect(tokenSymbol, "<"); err != nil {
+			return "", err
+		}
+		key, err := p.expect(tokenIdent, "")
+		if err != nil {
+			return "", err
+		}
+		if _, err := p.expect(tokenSymbol, ","); err != nil {
+			return "", err
+		}
+		value, err := p.expect(tokenIdent, "")
+		if err != nil {
+			return "", err
+		}
+		if _, err := p.expect(tokenSymbol, ">"); err != nil {
+			return "", err
+		}
+		return pbMap + "<" + key.text + ", " + value.text + ">", nil
+	default:
+		return t.text, nil
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"database/sql"
+	"testing"
+)
+
+type ParsedUser struct {
+	Name     string         `validate:"required,min=1"`
+	Nickname sql.NullString // nickname
+	Email    *string        `pb:"oneof_group=contact"`
+	Phone    *string        `pb:"oneof_group=contact"`
+	Tags     map[string][]string
+	Scores   []int32 `validate:"max=10"`
+}
+
+func TestParseProtoMessageRoundTrip(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{ParsedUser{}}, WithBufValidate(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := file.Messages[0]
+	m.Comment = "ParsedUser is a user.\nIt has two comment lines."
+	m.Options = map[string]string{"(acme.db.table)": `"users"`, "deprecated": "true"}
+	m.Fields[1].Comment = "the nickname"
+	m.ReservedTags = []int{20}
+	m.ReservedTagRanges = [][2]int{{30, 40}}
+	m.ReservedNames = []string{"old_name"}
+	if len(m.Oneofs) == 0 || len(m.NestedMessages) == 0 || len(m.Fields[0].Options()) == 0 {
+		t.Fatalf("message lacks oneofs, nested messages or field options:\n%s", m)
+	}
+
+	for _, v := range []SyntaxVersion{Proto3, Proto2, Edition2023} {
+		text := v.translateMessage(m).String()
+		parsed, err := ParseProtoMessage(text)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", v, err, text)
+		}
+		if got := parsed.String(); got != text {
+			t.Errorf("%s: round trip changed the message:\n%s\nwant:\n%s", v, got, text)
+		}
+	}
+}
+
+func TestParseProtoMessageSetWireFormat(t *testing.T) {
+	m := Message{Name: "Legacy", MessageSetWireFormat: true}
+	parsed, err := ParseProtoMessage(m.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.MessageSetWireFormat || len(parsed.Options) > 0 {
+		t.Errorf("parsed %+v", parsed)
+	}
+}
+
+type ParsedResource struct {
+	_    struct{} `pb:"resource_type=example.com/User,resource_pattern=users/{user}"`
+	Name string   `validate:"required"`
+	Role string   `validate:"oneof=admin user"`
+}
+
+func TestParseProtoMessageAggregateOptions(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{ParsedResource{}}, WithBufValidate(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := file.Messages[0]
+	if _, ok := m.Options[resourceOption]; !ok {
+		t.Fatalf("message lacks the resource option:\n%s", m)
+	}
+	m.Fields[0].options = append(m.Fields[0].options,
+		fieldOption(`(acme.field) = { names: ["a", "b"] nested { limit: -1 } }`))
+
+	text := m.String()
+	parsed, err := ParseProtoMessage(text)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, text)
+	}
+	if got := parsed.String(); got != text {
+		t.Errorf("round trip changed the message:\n%s\nwant:\n%s", got, text)
+	}
+	if got, want := parsed.Options[resourceOption], m.Options[resourceOption]; got != want {
+		t.Errorf("resource option = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// resourceImport is the proto file declaring the google.api.resource option.
+const resourceImport = "google/api/resource.proto"
+
+// resourceOption is the name of the message option of AIP-123 resources.
+const resourceOption = "(google.api.resource)"
+
+// resourceAnnotation is the type and name pattern of an AIP-123 resource.
+type resourceAnnotation struct {
+	resourceType string
+	pattern      string
+}
+
+// optionValue returns the value of the google.api.resource option.
+func (r resourceAnnotation) optionValue() string {
+	return fmt.Sprintf("{ type: %s pattern: %s }", strconv.Quote(r.resourceType), strconv.Quote(r.pattern))
+}
+
+// structResource returns the resource annotation declared by the pb struct tag of
+// a field of a struct, usually a blank one:
+//
+//	_ struct{} `pb:"resource_type=myapi.example.com/User,resource_pattern=users/{user}"`
+//
+// Otherwise the annotation set by WithResourceAnnotation is used for top-level
+// structs.
+func (c *converter) structResource(tags []string, depth int) (resourceAnnotation, bool, error) {
+	for _, tag := range tags {
+		directives := c.directives(tag)
+		resourceType, ok := directives["resource_type"]
+		if !ok {
+			continue
+		}
+		pattern := directives["resource_pattern"]
+		if len(resourceType) == 0 || len(pattern) == 0 {
+			return resourceAnnotation{}, false, fmt.Errorf("resource_type and resource_pattern must both be set: %s", tag)
+		}
+		return resourceAnnotation{resourceType, pattern}, true, nil
+	}
+	if c.resource != nil && depth == 0 {
+		return *c.resource, true, nil
+	}
+	return resourceAnnotation{}, false, nil
+}
+
+// addResource sets the google.api.resource option of msg if the struct converted
+// to it is a resource.
+func (c *converter) addResource(msg *Message, tags []string, depth int) error {
+	r, ok, err := c.structResource(tags, depth)
+	if err != nil {
+		return fmt.Errorf("%s: %w", msg.Name, err)
+	}
+	if ok {
+		msg.SetOption(resourceOption, r.optionValue())
+		c.addImport(resourceImport)
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	identRegexp    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	typeNameRegexp = regexp.MustCompile(`^\.?[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+)
+
+// RPC represents a method of a protocol buffer service.
+type RPC struct {
+	Name            string
+	Comment         string
+	RequestType     string
+	ResponseType    string
+	ClientStreaming bool
+	ServerStreaming bool
+	Options         map[string]string
+	// TimeoutSeconds and MaxRetries, if not zero, set the timeout and the number
+	// of retries of the RPC in the gRPC service config returned by
+	// ProtoFile.ServiceConfig, since proto files cannot declare them.
+	TimeoutSeconds float64
+	MaxRetries     int
+}
+
+// SetHTTPRule validates rule against the request message and sets it as the
+// google.api.http option of the RPC, which requires importing HTTPRuleImport.
+func (r *RPC) SetHTTPRule(rule HTTPRule, request Message) error {
+	if err := rule.Validate(request); err != nil {
+		return fmt.Errorf("rpc %s: %w", r.Name, err)
+	}
+	if r.Options == nil {
+		r.Options = make(map[string]string)
+	}
+	r.Options["(google.api.http)"] = rule.OptionValue()
+	return nil
+}
+
+// Validate checks the names and types of the RPC. Streaming must be set with
+// ClientStreaming and ServerStreaming rather than in the type names.
+func (r RPC) Validate() error {
+	if !identRegexp.MatchString(r.Name) {
+		return fmt.Errorf("rpc %q: invalid name", r.Name)
+	}
+	if r.TimeoutSeconds < 0 || r.MaxRetries < 0 {
+		return fmt.Errorf("rpc %s: negative timeout or retries", r.Name)
+	}
+	for _, typ := range []string{r.RequestType, r.ResponseType} {
+		if strings.HasPrefix(typ, "stream ") {
+			return fmt.Errorf("rpc %s: streaming of %s must be set with ClientStreaming or ServerStreaming", r.Name, typ)
+		}
+		if !typeNameRegexp.MatchString(typ) {
+			return fmt.Errorf("rpc %s: invalid type %q", r.Name, typ)
+		}
+	}
+	return nil
+}
+
+// String returns a string representation of an RPC.
+func (r RPC) String() string {
+	return r.stringWithStyle(defaultRenderStyle)
+}
+
+// stringWithStyle renders the RPC with its options indented by style.indent.
+func (r RPC) stringWithStyle(style renderStyle) string {
+	var buf bytes.Buffer
+
+	if len(r.Comment) > 0 {
+		buf.WriteString(fmt.Sprintf("// %s\n", r.Comment))
+	}
+	buf.WriteString(fmt.Sprintf("rpc %s (%s) returns (%s)", r.Name, streamType(r.ClientStreaming, r.RequestType), streamType(r.ServerStreaming, r.ResponseType)))
+	options := r.Options
+	if len(options) == 0 {
+		buf.WriteString(";\n")
+		return buf.String()
+	}
+	buf.WriteString(" {\n")
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteString(fmt.Sprintf("%soption %s = %s;\n", style.indent, name, options[name]))
+	}
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+// streamType returns the type of an RPC argument, prefixed with stream if it is
+// streamed.
+func streamType(streaming bool, typ string) string {
+	if streaming {
+		return "stream " + typ
+	}
+	return typ
+}
+
+// Service represents a protocol buffer service.
+type Service struct {
+	Name    string
+	Comment string
+	RPCs    []RPC
+}
+
+// AddRPC validates the RPC and appends it to the service. It returns an error if
+// the RPC is invalid or its name is already used.
+func (s *Service) AddRPC(r RPC) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	for _, existing := range s.RPCs {
+		if existing.Name == r.Name {
+			return fmt.Errorf("service %s: duplicate rpc %s", s.Name, r.Name)
+		}
+	}
+	s.RPCs = append(s.RPCs, r)
+	return nil
+}
+
+// Validate checks that the request and response types of the RPCs are declared
+// in file. Types qualified by another package are assumed to be imported, except
+// for the well-known google.protobuf types whose proto file must be imported.
+func (s Service) Validate(file *ProtoFile) []ValidationError {
+	scope := newTypeScope(file, false)
+	var errs []ValidationError
+	for _, r := range s.RPCs {
+		for _, typ := range []string{r.RequestType, r.ResponseType} {
+			if !scope.resolves(typ) {
+				errs = append(errs, ValidationError{Service: s.Name, RPC: r.Name, Type: typ})
+			}
+		}
+	}
+	return errs
+}
+
+// typeScope resolves type names against the declarations and imports of a file.
+type typeScope struct {
+	pkg      string
+	declared map[string]bool
+	imported map[string]bool
+}
+
+// newTypeScope collects the messages of file, with their nested messages by their
+// path such as Outer.Inner, and its enums if withEnums is set.
+func newTypeScope(file *ProtoFile, withEnums bool) typeScope {
+	scope := typeScope{
+		pkg:      file.Package,
+		declared: make(map[string]bool),
+		imported: make(map[string]bool, len(file.Imports)),
+	}
+	var collect func(prefix string, messages []Message)
+	collect = func(prefix string, messages []Message) {
+		for _, m := range messages {
+			scope.declared[prefix+m.Name] = true
+			collect(prefix+m.Name+".", m.NestedMessages)
+		}
+	}
+	collect("", file.Messages)
+	if withEnums {
+		for _, e := range file.Enums {
+			scope.declared[e.Name] = true
+		}
+	}
+	for _, path := range file.Imports {
+		scope.imported[path] = true
+	}
+	for _, path := range file.WeakImports {
+		scope.imported[path] = true
+	}
+	return scope
+}
+
+// resolves reports whether the type name is declared in the file, or imported.
+// Types qualified by another package are assumed to be imported if the file has
+// imports, while the well-known google.protobuf types need their own import.
+func (s typeScope) resolves(typ string) bool {
+	name := strings.TrimPrefix(typ, ".")
+	if len(s.pkg) > 0 {
+		name = strings.TrimPrefix(name, s.pkg+".")
+	}
+	if path, isWkt := protobufTypeFiles[name]; isWkt {
+		return s.imported[path]
+	}
+	if s.declared[name] {
+		return true
+	}
+	return strings.Contains(name, ".") && len(s.imported) > 0
+}
+
+// resolvesFrom is resolves for a type referenced inside the message at path, e.g.
+// Outer.Inner, where the messages nested in it and its parents are also in scope.
+func (s typeScope) resolvesFrom(path, typ string) bool {
+	for !strings.HasPrefix(typ, ".") && len(path) > 0 {
+		if s.declared[path+"."+typ] {
+			return true
+		}
+		if i := strings.LastIndex(path, "."); i >= 0 {
+			path = path[:i]
+		} else {
+			path = ""
+		}
+	}
+	return s.resolves(typ)
+}
+
+// String returns a string representation of a Service.
+func (s Service) String() string {
+	return s.stringWithStyle(defaultRenderStyle)
+}
+
+// stringWithStyle renders the service with its RPCs indented by style.indent.
+func (s Service) stringWithStyle(style renderStyle) string {
+	var buf bytes.Buffer
+
+	if len(s.Comment) > 0 {
+		buf.WriteString(fmt.Sprintf("// %s\n", s.Comment))
+	}
+	buf.WriteString(fmt.Sprintf("service %s {\n", s.Name))
+	for _, r := range s.RPCs {
+		for _, line := range strings.SplitAfter(r.stringWithStyle(style), "\n") {
+			if len(line) > 0 {
+				buf.WriteString(style.indent + line)
+			}
+		}
+	}
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+// protobufTypeFiles maps the well-known google.protobuf message types usable as
+// RPC arguments to the proto files declaring them.
+var protobufTypeFiles = map[string]string{
+	"google.protobuf.Empty":     "google/protobuf/empty.proto",
+	"google.protobuf.Any":       "google/protobuf/any.proto",
+	"google.protobuf.Struct":    "google/protobuf/struct.proto",
+	"google.protobuf.Timestamp": "google/protobuf/timestamp.proto",
+	"google.protobuf.Duration":  "google/protobuf/duration.proto",
+	"google.protobuf.FieldMask": "google/protobuf/field_mask.proto",
+}
+
+// Interface2Service converts the interface type t, such as
+// reflect.TypeOf((*UserService)(nil)).Elem(), to a service named after it with
+// an RPC for each method. The request and response types of the RPCs are derived
+// from the method names as set by WithRPCNamingConvention, and must be messages
+// declared in file. If the struct taken by a method declares an HTTP rule with an
+// http-rule tag, it is validated against the request message and set as the
+// google.api.http option of the RPC.
+func Interface2Service(t reflect.Type, file *ProtoFile, opts ...Option) (Service, error) {
+	if t == nil || t.Kind() != reflect.Interface {
+		return Service{}, fmt.Errorf("%v is not an interface", t)
+	}
+	o := newOptions(opts...)
+	scope := newTypeScope(file, false)
+	s := Service{Name: t.Name()}
+	for i := 0; i < t.NumMethod(); i++ {
+		name := t.Method(i).Name
+		request, response := o.rpcNaming(name)
+		for _, typ := range []string{request, response} {
+			if !scope.resolves(typ) {
+				return Service{}, fmt.Errorf("rpc %s: message %s is not declared", name, typ)
+			}
+		}
+		rpc := RPC{Name: name, RequestType: request, ResponseType: response}
+		if err := setMethodHTTPRule(&rpc, t.Method(i).Type, file); err != nil {
+			return Service{}, err
+		}
+		if err := s.AddRPC(rpc); err != nil {
+			return Service{}, err
+		}
+	}
+	return s, nil
+}
+
+// setMethodHTTPRule sets the HTTP rule declared by the request struct of the
+// method type m, the first struct or pointer to struct it takes, on the RPC.
+func setMethodHTTPRule(rpc *RPC, m reflect.Type, file *ProtoFile) error {
+	for i := 0; i < m.NumIn(); i++ {
+		in := m.In(i)
+		if in.Kind() == reflect.Ptr {
+			in = in.Elem()
+		}
+		if in.Kind() != reflect.Struct {
+			continue
+		}
+		rule, ok, err := httpRuleOf(in)
+		if err != nil {
+			return fmt.Errorf("rpc %s: %w", rpc.Name, err)
+		}
+		if !ok {
+			return nil
+		}
+		request, ok := file.LookupMessage(rpc.RequestType)
+		if !ok {
+			return fmt.Errorf("rpc %s: http rule needs message %s declared in the file", rpc.Name, rpc.RequestType)
+		}
+		return rpc.SetHTTPRule(rule, *request)
+	}
+	return nil
+}
+
+// defaultRPCNaming derives the request and response types of an RPC by appending
+// Request and Response to the method name.
+func defaultRPCNaming(methodName string) (requestMsg, responseMsg string) {
+	return methodName + "Request", methodName + "Response"
+}
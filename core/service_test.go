@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type GetUserRequest struct {
+	_  struct{} `http-rule:"GET /v1/users/{id}"`
+	ID string
+}
+
+type GetUserResponse struct {
+	Name string
+}
+
+type UserService interface {
+	GetUser(ctx context.Context, req *GetUserRequest) (*GetUserResponse, error)
+}
+
+func TestInterface2ServiceHTTPRule(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{GetUserRequest{}, GetUserResponse{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Interface2Service(reflect.TypeOf((*UserService)(nil)).Elem(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.AddService(s)
+	out := file.String()
+	for _, want := range []string{
+		`import "google/api/annotations.proto";`,
+		`option (google.api.http) = { get: "/v1/users/{id}" };`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+type BadRuleRequest struct {
+	_ struct{} `http-rule:"GET /v1/users/{user_id}"`
+}
+
+type BadRuleResponse struct{}
+
+type BadRuleService interface {
+	BadRule(req BadRuleRequest) BadRuleResponse
+}
+
+func TestInterface2ServiceHTTPRuleUnknownField(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{BadRuleRequest{}, BadRuleResponse{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Interface2Service(reflect.TypeOf((*BadRuleService)(nil)).Elem(), file); err == nil {
+		t.Error("http rule with unknown path variable accepted")
+	}
+}
+
+func TestRPCServiceConfig(t *testing.T) {
+	file, err := Structs2ProtoFile([]interface{}{GetUserRequest{}, GetUserResponse{}}, WithPackageName("acme.user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Service{Name: "UserService"}
+	for _, r := range []RPC{
+		{Name: "GetUser", RequestType: "GetUserRequest", ResponseType: "GetUserResponse", TimeoutSeconds: 1.5, MaxRetries: 3},
+		{Name: "WatchUser", RequestType: "GetUserRequest", ResponseType: "GetUserResponse", ServerStreaming: true, TimeoutSeconds: 30},
+		{Name: "FindUser", RequestType: "GetUserRequest", ResponseType: "GetUserResponse", MaxRetries: 10},
+		{Name: "PeekUser", RequestType: "GetUserRequest", ResponseType: "GetUserResponse"},
+	} {
+		if err := s.AddRPC(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file.AddService(s)
+	if out := file.String(); len(file.Imports) > 0 || strings.Contains(out, "option") {
+		t.Errorf("service config in the proto file:\n%s", out)
+	}
+	if methods := compileProto(t, file).Services().ByName("UserService").Methods(); methods.Len() != 4 {
+		t.Errorf("compiled %d methods", methods.Len())
+	}
+
+	config, err := file.ServiceConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "methodConfig": [
+    {
+      "name": [
+        {
+          "service": "acme.user.UserService",
+          "method": "GetUser"
+        }
+      ],
+      "timeout": "1.5s",
+      "retryPolicy": {
+        "maxAttempts": 4,
+        "initialBackoff": "0.1s",
+        "maxBackoff": "1s",
+        "backoffMultiplier": 2,
+        "retryableStatusCodes": [
+          "UNAVAILABLE"
+        ]
+      }
+    },
+    {
+      "name": [
+        {
+          "service": "acme.user.UserService",
+          "method": "WatchUser"
+        }
+      ],
+      "timeout": "30s"
+    },
+    {
+      "name": [
+        {
+          "service": "acme.user.UserService",
+          "method": "FindUser"
+        }
+      ],
+      "retryPolicy": {
+        "maxAttempts": 5,
+        "initialBackoff": "0.1s",
+        "maxBackoff": "1s",
+        "backoffMultiplier": 2,
+        "retryableStatusCodes": [
+          "UNAVAILABLE"
+        ]
+      }
+    }
+  ]
+}`
+	if string(config) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", config, want)
+	}
+
+	file.Services = nil
+	if config, err := file.ServiceConfig(); config != nil || err != nil {
+		t.Errorf("config without services = %s, %v", config, err)
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// The retry policy of the RPCs with MaxRetries, see
+// https://github.com/grpc/proposal/blob/master/A6-client-retries.md.
+const (
+	retryInitialBackoff    = "0.1s"
+	retryMaxBackoff        = "1s"
+	retryBackoffMultiplier = 2
+	// maxRetryAttempts is the limit of gRPC clients on the attempts of an RPC,
+	// higher values being lowered to it.
+	maxRetryAttempts = 5
+)
+
+// retryableStatusCodes are the status codes of the attempts that are retried.
+var retryableStatusCodes = []string{"UNAVAILABLE"}
+
+// serviceConfig is the JSON gRPC service config, with the method configs only.
+type serviceConfig struct {
+	MethodConfig []methodConfig `json:"methodConfig"`
+}
+
+// methodConfig is the timeout and retry policy of the methods it names.
+type methodConfig struct {
+	Name        []methodName `json:"name"`
+	Timeout     string       `json:"timeout,omitempty"`
+	RetryPolicy *retryPolicy `json:"retryPolicy,omitempty"`
+}
+
+// methodName names a method by the full name of its service.
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+}
+
+// retryPolicy is the retry policy of a method config.
+type retryPolicy struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+// methodConfig returns the method config of the RPC of the service with the given
+// full name. It reports false if neither TimeoutSeconds nor MaxRetries is set.
+func (r RPC) methodConfig(service string) (methodConfig, bool) {
+	if r.TimeoutSeconds <= 0 && r.MaxRetries <= 0 {
+		return methodConfig{}, false
+	}
+	mc := methodConfig{Name: []methodName{{Service: service, Method: r.Name}}}
+	if r.TimeoutSeconds > 0 {
+		// JSON的Duration最多精确到纳秒
+		seconds := math.Round(r.TimeoutSeconds*1e9) / 1e9
+		mc.Timeout = strconv.FormatFloat(seconds, 'f', -1, 64) + "s"
+	}
+	if r.MaxRetries > 0 {
+		// maxAttempts包括第一次调用
+		mc.RetryPolicy = &retryPolicy{
+			MaxAttempts:          r.MaxRetries + 1,
+			InitialBackoff:       retryInitialBackoff,
+			MaxBackoff:           retryMaxBackoff,
+			BackoffMultiplier:    retryBackoffMultiplier,
+			RetryableStatusCodes: retryableStatusCodes,
+		}
+		if mc.RetryPolicy.MaxAttempts > maxRetryAttempts {
+			mc.RetryPolicy.MaxAttempts = maxRetryAttempts
+		}
+	}
+	return mc, true
+}
+
+// ServiceConfig returns the gRPC service config in JSON, e.g. for
+// grpc.WithDefaultServiceConfig, with the timeouts and retry policies of the RPCs
+// of the services of the file that set TimeoutSeconds or MaxRetries. RPCs with
+// MaxRetries are retried on UNAVAILABLE with an exponential backoff from 0.1s to
+// 1s, at most 4 times as gRPC clients allow. It returns nil if no RPC sets them.
+func (f ProtoFile) ServiceConfig() ([]byte, error) {
+	var config serviceConfig
+	for _, s := range f.Services {
+		service := s.Name
+		if len(f.Package) > 0 {
+			service = f.Package + "." + s.Name
+		}
+		for _, r := range s.RPCs {
+			if mc, ok := r.methodConfig(service); ok {
+				config.MethodConfig = append(config.MethodConfig, mc)
+			}
+		}
+	}
+	if len(config.MethodConfig) == 0 {
+		return nil, nil
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
@@ -0,0 +1,148 @@
+package core
+
+import "strings"
+
+// SyntaxVersion is the syntax or edition a proto file is generated for.
+type SyntaxVersion int
+
+const (
+	// Proto3 generates `syntax = "proto3";` files, the default.
+	Proto3 SyntaxVersion = iota
+	// Proto2 generates `syntax = "proto2";` files, labelling singular fields optional.
+	Proto2
+	// Edition2023 generates `edition = "2023";` files, keeping the proto3 presence
+	// of fields with the field_presence feature.
+	Edition2023
+
+	// SyntaxVersionEdition2023 is Edition2023.
+	SyntaxVersionEdition2023 = Edition2023
+)
+
+// goFeaturesImport is the proto file declaring the features of the go plugin,
+// imported by edition files setting one of them.
+const goFeaturesImport = "google/protobuf/go_features.proto"
+
+// goFeatures is the extension of the features of the go plugin in option names,
+// e.g. features.(pb.go).legacy_unmarshal_json_enum.
+const goFeatures = "(pb.go)"
+
+// usesGoFeatures reports whether the file, its messages or their fields set a
+// feature of the go plugin.
+func (f ProtoFile) usesGoFeatures() bool {
+	for name := range f.Options {
+		if strings.Contains(name, goFeatures) {
+			return true
+		}
+	}
+	var inMessages func(messages []Message) bool
+	inMessages = func(messages []Message) bool {
+		for _, m := range messages {
+			for name := range m.Options {
+				if strings.Contains(name, goFeatures) {
+					return true
+				}
+			}
+			fields := m.Fields
+			for _, o := range m.Oneofs {
+				fields = append(fields[:len(fields):len(fields)], o.Fields...)
+			}
+			for _, field := range fields {
+				for _, opt := range field.Options() {
+					if strings.Contains(opt.protoOption(), goFeatures) {
+						return true
+					}
+				}
+			}
+			if inMessages(m.NestedMessages) {
+				return true
+			}
+		}
+		return false
+	}
+	return inMessages(f.Messages)
+}
+
+// scalarTypes are the proto scalar types, whose presence can be implicit.
+var scalarTypes = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true,
+	"uint64": true, "sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true, "bool": true, "string": true, "bytes": true,
+}
+
+// String returns the syntax or edition declaration of a proto file.
+func (v SyntaxVersion) String() string {
+	switch v {
+	case Proto2:
+		return `syntax = "proto2";`
+	case Edition2023:
+		return `edition = "2023";`
+	default:
+		return `syntax = "proto3";`
+	}
+}
+
+// translateMessage returns a copy of the proto3 message m with its field labels
+// and attributes translated to the syntax or edition.
+func (v SyntaxVersion) translateMessage(m Message) Message {
+	if v == Proto3 {
+		return m
+	}
+	fields := make([]MessageField, len(m.Fields))
+	for i, f := range m.Fields {
+		fields[i] = v.translateField(f)
+	}
+	m.Fields = fields
+	nested := make([]Message, len(m.NestedMessages))
+	for i, n := range m.NestedMessages {
+		nested[i] = v.translateMessage(n)
+	}
+	m.NestedMessages = nested
+	return m
+}
+
+// translateExtend returns a copy of the extend block e with its field labels
+// translated to the syntax or edition, like the fields of a message.
+func (v SyntaxVersion) translateExtend(e Extend) Extend {
+	if v == Proto3 {
+		return e
+	}
+	fields := make([]MessageField, len(e.Fields))
+	for i, f := range e.Fields {
+		if v == Edition2023 {
+			// 扩展字段总是显式存在，且不能设置field_presence特性
+			f.Typ = strings.TrimPrefix(f.Typ, pbOptional+fieldSep)
+			fields[i] = f
+			continue
+		}
+		fields[i] = v.translateField(f)
+	}
+	e.Fields = fields
+	return e
+}
+
+// translateField translates the label and attributes of a proto3 field. Oneof
+// fields need no translation, as they always have explicit presence.
+func (v SyntaxVersion) translateField(f MessageField) MessageField {
+	if f.IsRepeated() || f.IsMap() {
+		return f
+	}
+	switch v {
+	case Proto2:
+		if !strings.HasPrefix(f.Typ, pbOptional+fieldSep) {
+			f.Typ = pbOptional + fieldSep + f.Typ
+		}
+	case Edition2023:
+		// editions 不允许optional标签，由field_presence特性表示
+		presence := "IMPLICIT"
+		if typ := strings.TrimPrefix(f.Typ, pbOptional+fieldSep); typ != f.Typ {
+			f.Typ = typ
+			presence = "EXPLICIT"
+		} else if !scalarTypes[f.Typ] {
+			// 消息类型字段总是显式存在
+			return f
+		}
+		opts := make([]FieldOption, 0, len(f.options)+1)
+		f.options = append(append(opts, f.options...), fieldOption("features.field_presence = "+presence))
+	}
+	return f
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pbTagKey is the default struct tag key holding the field directives.
+	pbTagKey = "pb"
+
+	// maxFieldTag is the largest field number allowed by protobuf.
+	maxFieldTag = 536870911
+	// the field numbers from firstReservedTag to lastReservedTag are reserved for
+	// the protobuf implementation.
+	firstReservedTag = 19000
+	lastReservedTag  = 19999
+)
+
+// TagScheme controls how the fields converted from struct fields are numbered.
+// Oneof fields are numbered sequentially in all schemes.
+type TagScheme int
+
+const (
+	// TagSchemeSequential numbers the fields from 1 in declaration order.
+	TagSchemeSequential TagScheme = iota
+	// TagSchemeHashStable numbers a field with the FNV-1a hash of its name, so that
+	// adding a field never renumbers the existing ones, which makes it safe for
+	// evolving schemas. Colliding fields are renumbered as set by
+	// WithTagConflictResolver, in declaration order.
+	TagSchemeHashStable
+	// TagSchemeExplicit numbers the fields with their `pb:"tag=<N>"` struct tag,
+	// which every field must have.
+	TagSchemeExplicit
+)
+
+// parsePbTag parses the comma separated directives of a pb struct tag, e.g.
+// `pb:"map_value_tag=2"`. Directives without a value map to an empty string.
+func parsePbTag(tag string) map[string]string {
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(tag, ",") {
+		directive = strings.TrimSpace(directive)
+		if len(directive) == 0 {
+			continue
+		}
+		kv := strings.SplitN(directive, "=", 2)
+		if len(kv) == 2 {
+			directives[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		} else {
+			directives[directive] = ""
+		}
+	}
+	return directives
+}
+
+// directives parses the directives of a struct tag under the key set by
+// WithStructTagKey.
+func (c *converter) directives(structTag string) map[string]string {
+	return parsePbTag(reflect.StructTag(structTag).Get(c.structTagKey))
+}
+
+// tagDirective parses the field number of a directive, e.g. map_value_tag=2.
+// It returns def if the directive is not set.
+func tagDirective(directives map[string]string, name string, def int) (int, error) {
+	value, ok := directives[name]
+	if !ok {
+		return def, nil
+	}
+	tag, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	if err := validateTag(tag); err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return tag, nil
+}
+
+// validateTag checks that tag is a valid field number, returning an
+// InvalidTagError otherwise.
+func validateTag(tag int) error {
+	if tag < 1 || tag > maxFieldTag || (tag >= firstReservedTag && tag <= lastReservedTag) {
+		return InvalidTagError{Tag: tag}
+	}
+	return nil
+}
+
+// resolveTagConflicts renumbers the fields of m whose tag is already used by a
+// previous field, with the resolver set by WithTagConflictResolver or, by default,
+// the next free tag. Resolved tags falling in the reserved range are then moved
+// past it.
+func (c *converter) resolveTagConflicts(m *Message) error {
+	fields := make([]*MessageField, 0, len(m.Fields))
+	for i := range m.Fields {
+		fields = append(fields, &m.Fields[i])
+	}
+	for i := range m.Oneofs {
+		for j := range m.Oneofs[i].Fields {
+			fields = append(fields, &m.Oneofs[i].Fields[j])
+		}
+	}
+
+	used := make(map[int]*MessageField, len(fields))
+	for _, f := range fields {
+		existing, ok := used[f.Tag()]
+		if !ok {
+			used[f.Tag()] = f
+			continue
+		}
+		tag := f.Tag()
+		if c.tagConflictResolver != nil {
+			tag = c.tagConflictResolver(*existing, *f)
+		} else {
+			for used[tag] != nil {
+				tag++
+			}
+		}
+		// 解决冲突后跳过保留范围
+		if tag >= firstReservedTag && tag <= lastReservedTag {
+			tag = lastReservedTag + 1
+			for c.tagConflictResolver == nil && used[tag] != nil {
+				tag++
+			}
+		}
+		if _, ok := used[tag]; ok || tag < 1 || tag > maxFieldTag {
+			return fmt.Errorf("message %s: cannot resolve tag %d of field %s conflicting with field %s", m.Name, f.Tag(), f.Name, existing.Name)
+		}
+		*f = f.WithTag(tag)
+		used[tag] = f
+	}
+	return nil
+}
+
+// field categories ordered by WithFieldOrderByCategory.
+const (
+	categoryScalar = iota
+	categoryMessage
+	categoryMap
+	categoryRepeated
+)
+
+// fieldCategory returns the category of a field: scalar (including optional
+// scalars), singular message, map or repeated.
+func fieldCategory(f MessageField) int {
+	switch {
+	case f.IsMap():
+		return categoryMap
+	case f.IsRepeated():
+		return categoryRepeated
+	case scalarTypes[f.ProtoType()]:
+		return categoryScalar
+	default:
+		return categoryMessage
+	}
+}
+
+// orderFieldsByCategory sorts the fields of m by category, keeping the declaration
+// order within a category. With TagSchemeSequential the fields are then renumbered
+// from 1 in the new order, followed by the oneof fields.
+func (c *converter) orderFieldsByCategory(m *Message) {
+	sort.SliceStable(m.Fields, func(i, j int) bool {
+		return fieldCategory(m.Fields[i]) < fieldCategory(m.Fields[j])
+	})
+	if c.tagScheme == TagSchemeSequential {
+		m.renumberFields()
+	}
+}
+
+// newField creates a message field numbered according to the tag scheme, index
+// being its sequential number and structTag the struct tag of its struct field.
+// Invalid numbers are an error in strict mode, and otherwise moved to the next
+// valid number.
+func (c *converter) newField(typ, name, structTag string, index int, comment string) (MessageField, error) {
+	tag := index
+	switch c.tagScheme {
+	case TagSchemeHashStable:
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		tag = int(h.Sum32()%maxFieldTag) + 1
+		// 跳过保留范围
+		if tag >= firstReservedTag && tag <= lastReservedTag {
+			tag += lastReservedTag - firstReservedTag + 1
+		}
+	case TagSchemeExplicit:
+		var err error
+		tag, err = tagDirective(c.directives(structTag), "tag", 0)
+		if err != nil {
+			return MessageField{}, fmt.Errorf("%s: %w", name, err)
+		}
+		if tag == 0 {
+			return MessageField{}, fmt.Errorf("%s: missing `%s:\"tag=<N>\"` struct tag", name, c.structTagKey)
+		}
+	}
+	if err := validateTag(tag); err != nil {
+		if c.strictMode || tag > maxFieldTag {
+			return MessageField{}, fmt.Errorf("%s: %w", name, err)
+		}
+		// 非严格模式下调整为下一个有效的编号，冲突在之后处理
+		if tag < 1 {
+			tag = 1
+		} else {
+			tag = lastReservedTag + 1
+		}
+	}
+	f := NewMessageField(typ, name, tag, comment)
+	if c.bufValidate {
+		f.options = c.bufValidateRules(structTag, typ)
+	}
+	return f, nil
+}
@@ -0,0 +1,11 @@
+package core
+
+// version is the version of struct2pb.
+const version = "v0.1.0"
+
+// Version returns the version of struct2pb, e.g. for tools embedding it to note
+// in the header of generated files: `// Generated by struct2pb v0.1.0`, which
+// WithProtoSyntaxComment writes.
+func Version() string {
+	return version
+}
@@ -0,0 +1,47 @@
+package core
+
+import "strings"
+
+// WireType is the protobuf wire type of an encoded field.
+type WireType int
+
+// wire types, numbered as in the protobuf encoding.
+const (
+	WireVarint          WireType = 0
+	Wire64Bit           WireType = 1
+	WireLengthDelimited WireType = 2
+	Wire32Bit           WireType = 5
+)
+
+// scalarWireTypes maps the proto scalar types to their wire types.
+var scalarWireTypes = map[string]WireType{
+	"int32":    WireVarint,
+	"int64":    WireVarint,
+	"uint32":   WireVarint,
+	"uint64":   WireVarint,
+	"sint32":   WireVarint,
+	"sint64":   WireVarint,
+	"bool":     WireVarint,
+	"fixed64":  Wire64Bit,
+	"sfixed64": Wire64Bit,
+	"double":   Wire64Bit,
+	"fixed32":  Wire32Bit,
+	"sfixed32": Wire32Bit,
+	"float":    Wire32Bit,
+	"string":   WireLengthDelimited,
+	"bytes":    WireLengthDelimited,
+}
+
+// WireType returns the wire type of the field. Repeated fields are assumed to be
+// packed, and map fields are repeated entry messages, so both are length-delimited.
+// Fields of a named type are treated as messages, since enums cannot be told
+// apart from their type name.
+func (f MessageField) WireType() WireType {
+	if f.IsRepeated() || f.IsMap() {
+		return WireLengthDelimited
+	}
+	if wt, ok := scalarWireTypes[strings.TrimPrefix(f.Typ, pbOptional+fieldSep)]; ok {
+		return wt
+	}
+	return WireLengthDelimited
+}
@@ -1,12 +1,54 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"struct2pb/core"
 	"struct2pb/obj"
 )
 
+var (
+	pkgFlag   = flag.String("pkg", "", "import path of the package to convert, instead of obj.List")
+	outFlag   = flag.String("out", "", "proto file to write, instead of printing to the console")
+	typesFlag = flag.String("types", "", "comma-separated structs of -pkg to convert, all exported structs if empty")
+)
+
 func main() {
-	result := core.Structs2Pb(true, obj.List...)
-	fmt.Println(result)
+	flag.Parse()
+	if len(*pkgFlag) == 0 && len(*typesFlag) > 0 {
+		log.Fatal("-types requires -pkg")
+	}
+
+	var (
+		file *core.ProtoFile
+		err  error
+	)
+	if len(*pkgFlag) > 0 {
+		var types []string
+		if len(*typesFlag) > 0 {
+			types = strings.Split(*typesFlag, ",")
+		}
+		file, err = core.Packages2ProtoFile([]string{*pkgFlag}, types, core.WithStrictMode(true))
+	} else {
+		file, err = core.Structs2ProtoFile(obj.List, core.WithStrictMode(true))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(*outFlag) == 0 {
+		fmt.Print(file.String())
+		return
+	}
+	data, err := file.Marshal(core.OutputFormatTextProto, filepath.Base(*outFlag))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*outFlag, data, 0644); err != nil {
+		log.Fatal(err)
+	}
 }